@@ -0,0 +1,67 @@
+package chaincode
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// amountPattern matches a base-10 integer with an optional minus sign
+var amountPattern = regexp.MustCompile(`^-?[0-9]+$`)
+
+// parseAmount parses a token amount given as a base-10 integer string of any size
+// Callers check the sign, since each function accepts a different range
+func parseAmount(amount string) (*big.Int, error) {
+
+	value, ok := new(big.Int).SetString(amount, 10)
+	if !amountPattern.MatchString(amount) || !ok {
+		return nil, fmt.Errorf("amount %q is not a base-10 integer", amount)
+	}
+
+	return value, nil
+}
+
+// readAmount reads the amount stored under key, which is 0 if the key does not exist
+// found reports whether the key exists; amounts that cannot be parsed are an error rather than 0
+func readAmount(ctx contractapi.TransactionContextInterface, key string) (amount *big.Int, found bool, err error) {
+
+	amountBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, false, err
+	}
+	if amountBytes == nil {
+		return new(big.Int), false, nil
+	}
+
+	amount, err = parseAmount(string(amountBytes))
+	if err != nil {
+		return nil, true, fmt.Errorf("malformed amount stored under %s: %v", key, err)
+	}
+	if amount.Sign() < 0 {
+		return nil, true, fmt.Errorf("malformed amount stored under %s: %s is negative", key, amount)
+	}
+
+	return amount, true, nil
+}
+
+// putAmount stores the amount under key as a base-10 integer string
+func putAmount(ctx contractapi.TransactionContextInterface, key string, amount *big.Int) error {
+
+	if amount.Sign() < 0 {
+		return fmt.Errorf("cannot store negative amount %s under %s", amount, key)
+	}
+
+	return ctx.GetStub().PutState(key, []byte(amount.String()))
+}
+
+// subAmount returns a - b, failing rather than going below 0
+func subAmount(a *big.Int, b *big.Int) (*big.Int, error) {
+
+	if a.Cmp(b) < 0 {
+		return nil, fmt.Errorf("cannot subtract %s from %s", b, a)
+	}
+
+	return new(big.Int).Sub(a, b), nil
+}
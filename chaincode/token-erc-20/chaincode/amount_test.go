@@ -0,0 +1,82 @@
+package chaincode_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_LargeAmounts(t *testing.T) {
+	fmt.Println("Test_LargeAmounts-----------------")
+	NewInitializedStub(t)
+
+	// 2^64 does not fit in an int64, and neither does twice it
+	_, err := Invoke("Mint", "18446744073709551616")
+	require.NoError(t, err)
+	AssertEvent(t, "Transfer", `{"from":"0x0","to":"`+AsOwner()+`","value":"18446744073709551616"}`)
+	_, err = Invoke("Mint", "18446744073709551616")
+	require.NoError(t, err)
+	result, err := Invoke("TotalSupply")
+	require.NoError(t, err)
+	assert.Equal(t, "36893488147419103232", result)
+
+	alice := As("alice")
+	AsOwner()
+	_, err = Invoke("Transfer", alice, "9223372036854775808")
+	require.NoError(t, err)
+	result, err = Invoke("ClientAccountBalance")
+	require.NoError(t, err)
+	assert.Equal(t, "27670116110564327424", result)
+	result, err = Invoke("BalanceOf", alice)
+	require.NoError(t, err)
+	assert.Equal(t, "9223372036854775808", result)
+
+	_, err = Invoke("Transfer", alice, "27670116110564327425")
+	assert.EqualError(t, err, fmt.Sprintf("failed to transfer: client account %s has insufficient funds", AsOwner()))
+}
+
+func Test_MalformedAmounts(t *testing.T) {
+	fmt.Println("Test_MalformedAmounts-----------------")
+	NewInitializedStub(t)
+
+	for _, amount := range []string{"", "1.5", "1e3", "+5", " 5", "0x10", "five"} {
+		_, err := Invoke("Mint", amount)
+		assert.EqualError(t, err, fmt.Sprintf("amount %q is not a base-10 integer", amount))
+	}
+	_, err := Invoke("Mint", "0")
+	assert.EqualError(t, err, "mint amount must be a positive integer")
+	_, err = Invoke("Mint", "-5")
+	assert.EqualError(t, err, "mint amount must be a positive integer")
+	alice := As("alice")
+	AsOwner()
+	_, err = Invoke("Approve", alice, "-5")
+	assert.EqualError(t, err, "allowance cannot be negative")
+	_, err = Invoke("Transfer", alice, "-5")
+	assert.EqualError(t, err, "failed to transfer: transfer amount cannot be negative")
+}
+
+func Test_MalformedStoredBalances(t *testing.T) {
+	fmt.Println("Test_MalformedStoredBalances-----------------")
+	NewInitializedStub(t)
+	_, err := Invoke("Mint", "100")
+	require.NoError(t, err)
+
+	// Stored balances that are not integers or are negative are errors rather than 0
+	alice := As("alice")
+	PutLedgerState(alice, "abc")
+	_, err = Invoke("BalanceOf", alice)
+	assert.EqualError(t, err, fmt.Sprintf(`failed to read from world state: malformed amount stored under %s: amount "abc" is not a base-10 integer`, alice))
+	AsOwner()
+	_, err = Invoke("Transfer", alice, "10")
+	assert.EqualError(t, err, fmt.Sprintf(`failed to transfer: failed to read recipient account %s from world state: malformed amount stored under %s: amount "abc" is not a base-10 integer`, alice, alice))
+
+	PutLedgerState(alice, "-5")
+	_, err = Invoke("BalanceOf", alice)
+	assert.EqualError(t, err, fmt.Sprintf("failed to read from world state: malformed amount stored under %s: -5 is negative", alice))
+
+	result, err := Invoke("ClientAccountBalance")
+	require.NoError(t, err)
+	assert.Equal(t, "100", result)
+}
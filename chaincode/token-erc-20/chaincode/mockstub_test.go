@@ -0,0 +1,120 @@
+package chaincode_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// MockStub extends shimtest.MockStub so that the contract sees the
+// overridden methods below, and records the event of the last invocation
+type MockStub struct {
+	*shimtest.MockStub
+	cc   shim.Chaincode
+	args [][]byte
+
+	// Event is the chaincode event set by the last invocation, if any
+	Event *pb.ChaincodeEvent
+}
+
+func NewMockStub(name string, cc shim.Chaincode) *MockStub {
+	return &MockStub{
+		MockStub: shimtest.NewMockStub(name, cc),
+		cc:       cc,
+	}
+}
+
+// MockInvoke invokes the chaincode with this stub, so the overridden
+// methods below are the ones the contract sees
+func (stub *MockStub) MockInvoke(uuid string, args [][]byte) pb.Response {
+	stub.args = args
+	stub.Event = nil
+	stub.MockTransactionStart(uuid)
+	res := stub.cc.Invoke(stub)
+	stub.MockTransactionEnd(uuid)
+	return res
+}
+
+func (stub *MockStub) GetArgs() [][]byte {
+	return stub.args
+}
+
+func (stub *MockStub) GetStringArgs() []string {
+	strargs := make([]string, 0, len(stub.args))
+	for _, barg := range stub.args {
+		strargs = append(strargs, string(barg))
+	}
+	return strargs
+}
+
+func (stub *MockStub) GetFunctionAndParameters() (function string, params []string) {
+	allargs := stub.GetStringArgs()
+	params = []string{}
+	if len(allargs) >= 1 {
+		function = allargs[0]
+		params = allargs[1:]
+	}
+	return
+}
+
+// SetEvent keeps only the last event of a transaction, as the peer does
+func (stub *MockStub) SetEvent(name string, payload []byte) error {
+	stub.Event = &pb.ChaincodeEvent{EventName: name, Payload: payload}
+	return nil
+}
+
+// SetCreator makes the following invocations come from the client with the
+// given common name in mspId, and returns its client ID
+func (stub *MockStub) SetCreator(mspId string, name string) string {
+	creator, err := newCreator(mspId, name)
+	if err != nil {
+		panic(err)
+	}
+	stub.Creator = creator
+
+	identity, err := cid.New(stub)
+	if err != nil {
+		panic(err)
+	}
+	clientID, err := identity.GetID()
+	if err != nil {
+		panic(err)
+	}
+	return clientID
+}
+
+// newCreator returns a serialized identity with a self-signed certificate
+func newCreator(mspId string, name string) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name + "@" + mspId},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspId,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}),
+	})
+}
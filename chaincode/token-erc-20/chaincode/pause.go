@@ -0,0 +1,133 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define option names, stored under config~option keys so that no account ID can collide with them
+const pausedOption = "paused"
+
+// pauseEvent provides an organized struct for emitting Paused and Unpaused events
+type pauseEvent struct {
+	Sender string `json:"sender"`
+}
+
+// Pause blocks Mint, Burn, BurnFrom, Transfer, TransferFrom and Approve until Unpause is called
+// Queries keep working while the contract is paused
+// Only the contract owner can pause the contract
+// This function triggers a Paused event
+func (s *SmartContract) Pause(ctx contractapi.TransactionContextInterface) error {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return err
+	}
+
+	return setPaused(ctx, true)
+}
+
+// Unpause lifts a Pause
+// Only the contract owner can unpause the contract
+// This function triggers an Unpaused event
+func (s *SmartContract) Unpause(ctx contractapi.TransactionContextInterface) error {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return err
+	}
+
+	return setPaused(ctx, false)
+}
+
+// Paused returns whether the contract is paused
+func (s *SmartContract) Paused(ctx contractapi.TransactionContextInterface) (bool, error) {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return false, err
+	}
+
+	return isPaused(ctx)
+}
+
+// Helper Functions
+
+// isPaused returns whether the contract is paused
+func isPaused(ctx contractapi.TransactionContextInterface) (bool, error) {
+
+	pausedBytes, err := getOption(ctx, pausedOption)
+	if err != nil {
+		return false, fmt.Errorf("failed to read paused state from world state: %v", err)
+	}
+
+	return pausedBytes != nil, nil
+}
+
+// checkNotPaused returns an error if the contract is paused
+func checkNotPaused(ctx contractapi.TransactionContextInterface) error {
+
+	paused, err := isPaused(ctx)
+	if err != nil {
+		return err
+	}
+	if paused {
+		return fmt.Errorf("contract is paused")
+	}
+
+	return nil
+}
+
+// setPaused records the paused state, if the submitting client is the contract owner,
+// and triggers a Paused or Unpaused event
+func setPaused(ctx contractapi.TransactionContextInterface, paused bool) error {
+
+	sender, err := checkOwner(ctx)
+	if err != nil {
+		return err
+	}
+
+	current, err := isPaused(ctx)
+	if err != nil {
+		return err
+	}
+	if current == paused {
+		if paused {
+			return fmt.Errorf("contract is already paused")
+		}
+		return fmt.Errorf("contract is not paused")
+	}
+
+	pausedKey, err := configKey(ctx, pausedOption)
+	if err != nil {
+		return err
+	}
+
+	// The paused state only needs its key, so store a marker as the value and delete it to unpause
+	eventName := "Paused"
+	if paused {
+		err = ctx.GetStub().PutState(pausedKey, []byte{0x01})
+	} else {
+		eventName = "Unpaused"
+		err = ctx.GetStub().DelState(pausedKey)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update paused state: %v", err)
+	}
+
+	pauseEventJSON, err := json.Marshal(pauseEvent{sender})
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent(eventName, pauseEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	log.Printf("%s event triggered by %s", eventName, sender)
+
+	return nil
+}
@@ -0,0 +1,76 @@
+package chaincode_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_Pause(t *testing.T) {
+	fmt.Println("Test_Pause-----------------")
+	owner := NewInitializedStub(t)
+	alice := As("alice")
+	AsOwner()
+	_, err := Invoke("Mint", "100")
+	require.NoError(t, err)
+	_, err = Invoke("Approve", alice, "50")
+	require.NoError(t, err)
+
+	As("alice")
+	_, err = Invoke("Pause")
+	assert.EqualError(t, err, "client is not the contract owner")
+
+	AsOwner()
+	_, err = Invoke("Unpause")
+	assert.EqualError(t, err, "contract is not paused")
+	_, err = Invoke("Pause")
+	require.NoError(t, err)
+	AssertEvent(t, "Paused", fmt.Sprintf(`{"sender":%q}`, owner))
+	_, err = Invoke("Pause")
+	assert.EqualError(t, err, "contract is already paused")
+	result, err := Invoke("Paused")
+	require.NoError(t, err)
+	assert.Equal(t, "true", result)
+
+	// Every function that moves tokens or allowances is blocked
+	for _, args := range [][]string{
+		{"Mint", "10"},
+		{"Burn", "10"},
+		{"BurnFrom", alice, "10"},
+		{"Transfer", alice, "10"},
+		{"Approve", alice, "10"},
+	} {
+		_, err = Invoke(args[0], args[1:]...)
+		assert.EqualError(t, err, "contract is paused", args[0])
+	}
+	As("alice")
+	_, err = Invoke("TransferFrom", owner, alice, "10")
+	assert.EqualError(t, err, "contract is paused")
+
+	// Queries keep working
+	result, err = Invoke("BalanceOf", owner)
+	require.NoError(t, err)
+	assert.Equal(t, "100", result)
+	result, err = Invoke("Allowance", owner, alice)
+	require.NoError(t, err)
+	assert.Equal(t, "50", result)
+	result, err = Invoke("TotalSupply")
+	require.NoError(t, err)
+	assert.Equal(t, "100", result)
+
+	_, err = Invoke("Unpause")
+	assert.EqualError(t, err, "client is not the contract owner")
+	AsOwner()
+	_, err = Invoke("Unpause")
+	require.NoError(t, err)
+	AssertEvent(t, "Unpaused", fmt.Sprintf(`{"sender":%q}`, owner))
+	result, err = Invoke("Paused")
+	require.NoError(t, err)
+	assert.Equal(t, "false", result)
+
+	As("alice")
+	_, err = Invoke("TransferFrom", owner, alice, "10")
+	require.NoError(t, err)
+}
@@ -0,0 +1,252 @@
+package chaincode
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Define objectType names for prefix
+const minterPrefix = "minter"
+
+// Define role names
+const minterRole = "minter"
+
+// Define principal types a role can be granted to
+const (
+	principalClient = "client"
+	principalMSP    = "msp"
+)
+
+// roleEvent provides an organized struct for emitting RoleGranted and RoleRevoked events
+type roleEvent struct {
+	Role          string `json:"role"`
+	PrincipalType string `json:"principalType"`
+	Principal     string `json:"principal"`
+	Sender        string `json:"sender"`
+}
+
+// GrantMinter grants the minter role to a client ID, as returned by the ClientAccountID() function,
+// or to every client of an MSP, depending on principalType ("client" or "msp")
+// Only the contract owner can grant roles
+// This function triggers a RoleGranted event
+func (s *SmartContract) GrantMinter(ctx contractapi.TransactionContextInterface, principalType string, principal string) error {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return err
+	}
+
+	sender, err := checkOwner(ctx)
+	if err != nil {
+		return err
+	}
+
+	minterKey, err := minterRoleKey(ctx, principalType, principal)
+	if err != nil {
+		return err
+	}
+	minterBytes, err := ctx.GetStub().GetState(minterKey)
+	if err != nil {
+		return fmt.Errorf("failed to read minter role from world state: %v", err)
+	}
+	if minterBytes != nil {
+		return fmt.Errorf("%s %s already has the minter role", principalType, principal)
+	}
+
+	return grantMinter(ctx, sender, principalType, principal)
+}
+
+// RevokeMinter revokes the minter role from a client ID or an MSP
+// Only the contract owner can revoke roles
+// This function triggers a RoleRevoked event
+func (s *SmartContract) RevokeMinter(ctx contractapi.TransactionContextInterface, principalType string, principal string) error {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return err
+	}
+
+	sender, err := checkOwner(ctx)
+	if err != nil {
+		return err
+	}
+
+	minterKey, err := minterRoleKey(ctx, principalType, principal)
+	if err != nil {
+		return err
+	}
+	minterBytes, err := ctx.GetStub().GetState(minterKey)
+	if err != nil {
+		return fmt.Errorf("failed to read minter role from world state: %v", err)
+	}
+	if minterBytes == nil {
+		return fmt.Errorf("%s %s does not have the minter role", principalType, principal)
+	}
+
+	err = ctx.GetStub().DelState(minterKey)
+	if err != nil {
+		return fmt.Errorf("failed to revoke minter role: %v", err)
+	}
+
+	err = emitRoleEvent(ctx, "RoleRevoked", roleEvent{minterRole, principalType, principal, sender})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("minter role of %s %s revoked by %s", principalType, principal, sender)
+
+	return nil
+}
+
+// IsMinter returns whether the minter role is granted to the client ID or MSP
+func (s *SmartContract) IsMinter(ctx contractapi.TransactionContextInterface, principalType string, principal string) (bool, error) {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return false, err
+	}
+
+	minterKey, err := minterRoleKey(ctx, principalType, principal)
+	if err != nil {
+		return false, err
+	}
+	minterBytes, err := ctx.GetStub().GetState(minterKey)
+	if err != nil {
+		return false, fmt.Errorf("failed to read minter role from world state: %v", err)
+	}
+
+	return minterBytes != nil, nil
+}
+
+// Owner returns the client ID of the contract owner
+func (s *SmartContract) Owner(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return "", err
+	}
+
+	ownerBytes, err := getOption(ctx, ownerOption)
+	if err != nil {
+		return "", fmt.Errorf("failed to read owner from world state: %v", err)
+	}
+	if ownerBytes == nil {
+		return "", fmt.Errorf("contract is not initialized")
+	}
+
+	return string(ownerBytes), nil
+}
+
+// Helper Functions
+
+// checkOwner returns the ID of the submitting client if it is the contract owner
+func checkOwner(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	ownerBytes, err := getOption(ctx, ownerOption)
+	if err != nil {
+		return "", fmt.Errorf("failed to read owner from world state: %v", err)
+	}
+	if ownerBytes == nil {
+		return "", fmt.Errorf("contract is not initialized")
+	}
+	if string(ownerBytes) != clientID {
+		return "", fmt.Errorf("client is not the contract owner")
+	}
+
+	return clientID, nil
+}
+
+// checkMinter returns an error unless the submitting client, or its MSP, has the minter role
+func checkMinter(ctx contractapi.TransactionContextInterface) error {
+
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSPID: %v", err)
+	}
+
+	// Check the client ID before its MSP, in the same order on every endorser so their read sets match
+	for _, principal := range [][2]string{{principalClient, clientID}, {principalMSP, clientMSPID}} {
+		minterKey, err := minterRoleKey(ctx, principal[0], principal[1])
+		if err != nil {
+			return err
+		}
+		minterBytes, err := ctx.GetStub().GetState(minterKey)
+		if err != nil {
+			return fmt.Errorf("failed to read minter role from world state: %v", err)
+		}
+		if minterBytes != nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("client is not authorized to mint or burn tokens")
+}
+
+// grantMinter records the minter role of a client ID or MSP and triggers a RoleGranted event
+func grantMinter(ctx contractapi.TransactionContextInterface, sender string, principalType string, principal string) error {
+
+	minterKey, err := minterRoleKey(ctx, principalType, principal)
+	if err != nil {
+		return err
+	}
+
+	// Role entries only need their key, so store a marker as the value
+	err = ctx.GetStub().PutState(minterKey, []byte{0x01})
+	if err != nil {
+		return fmt.Errorf("failed to grant minter role: %v", err)
+	}
+
+	err = emitRoleEvent(ctx, "RoleGranted", roleEvent{minterRole, principalType, principal, sender})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("minter role granted to %s %s by %s", principalType, principal, sender)
+
+	return nil
+}
+
+// minterRoleKey returns the minter~principalType~principal key of a minter role
+func minterRoleKey(ctx contractapi.TransactionContextInterface, principalType string, principal string) (string, error) {
+
+	if principalType != principalClient && principalType != principalMSP {
+		return "", fmt.Errorf("principal type must be %s or %s", principalClient, principalMSP)
+	}
+	if principal == "" {
+		return "", fmt.Errorf("principal must not be empty")
+	}
+
+	minterKey, err := ctx.GetStub().CreateCompositeKey(minterPrefix, []string{principalType, principal})
+	if err != nil {
+		return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", minterPrefix, err)
+	}
+
+	return minterKey, nil
+}
+
+// emitRoleEvent triggers a RoleGranted or RoleRevoked event
+func emitRoleEvent(ctx contractapi.TransactionContextInterface, name string, roleEvent roleEvent) error {
+
+	roleEventJSON, err := json.Marshal(roleEvent)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent(name, roleEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+
+	return nil
+}
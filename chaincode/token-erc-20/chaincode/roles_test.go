@@ -0,0 +1,90 @@
+package chaincode_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_GrantMinter(t *testing.T) {
+	fmt.Println("Test_GrantMinter-----------------")
+	owner := NewInitializedStub(t)
+
+	result, err := Invoke("Owner")
+	require.NoError(t, err)
+	assert.Equal(t, owner, result)
+	result, err = Invoke("IsMinter", "client", owner)
+	require.NoError(t, err)
+	assert.Equal(t, "true", result)
+
+	alice := As("alice")
+	_, err = Invoke("Mint", "100")
+	assert.EqualError(t, err, "client is not authorized to mint or burn tokens")
+	_, err = Invoke("GrantMinter", "client", alice)
+	assert.EqualError(t, err, "client is not the contract owner")
+
+	AsOwner()
+	_, err = Invoke("GrantMinter", "client", alice)
+	require.NoError(t, err)
+	AssertEvent(t, "RoleGranted", fmt.Sprintf(`{"role":"minter","principalType":"client","principal":%q,"sender":%q}`, alice, owner))
+	_, err = Invoke("GrantMinter", "client", alice)
+	assert.EqualError(t, err, fmt.Sprintf("client %s already has the minter role", alice))
+	_, err = Invoke("GrantMinter", "user", alice)
+	assert.EqualError(t, err, "principal type must be client or msp")
+
+	As("alice")
+	_, err = Invoke("Mint", "100")
+	require.NoError(t, err)
+	result, err = Invoke("ClientAccountBalance")
+	require.NoError(t, err)
+	assert.Equal(t, "100", result)
+}
+
+func Test_GrantMinterToMSP(t *testing.T) {
+	fmt.Println("Test_GrantMinterToMSP-----------------")
+	NewInitializedStub(t)
+
+	_, err := Invoke("GrantMinter", "msp", "Org2MSP")
+	require.NoError(t, err)
+	result, err := Invoke("IsMinter", "msp", "Org2MSP")
+	require.NoError(t, err)
+	assert.Equal(t, "true", result)
+
+	// Every client of the MSP can mint, without a grant of its own
+	bob := As("bob")
+	result, err = Invoke("IsMinter", "client", bob)
+	require.NoError(t, err)
+	assert.Equal(t, "false", result)
+	_, err = Invoke("Mint", "50")
+	require.NoError(t, err)
+
+	Stub.SetCreator("Org3MSP", "carol")
+	_, err = Invoke("Mint", "50")
+	assert.EqualError(t, err, "client is not authorized to mint or burn tokens")
+}
+
+func Test_RevokeMinter(t *testing.T) {
+	fmt.Println("Test_RevokeMinter-----------------")
+	owner := NewInitializedStub(t)
+	alice := As("alice")
+	AsOwner()
+	_, err := Invoke("GrantMinter", "client", alice)
+	require.NoError(t, err)
+
+	As("alice")
+	_, err = Invoke("RevokeMinter", "client", owner)
+	assert.EqualError(t, err, "client is not the contract owner")
+
+	AsOwner()
+	_, err = Invoke("RevokeMinter", "client", alice)
+	require.NoError(t, err)
+	AssertEvent(t, "RoleRevoked", fmt.Sprintf(`{"role":"minter","principalType":"client","principal":%q,"sender":%q}`, alice, owner))
+	_, err = Invoke("RevokeMinter", "client", alice)
+	assert.EqualError(t, err, fmt.Sprintf("client %s does not have the minter role", alice))
+
+	As("alice")
+	_, err = Invoke("Mint", "100")
+	assert.EqualError(t, err, "client is not authorized to mint or burn tokens")
+}
@@ -5,16 +5,27 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/big"
 	"strconv"
+	"strings"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
-// Define key names for options
-const totalSupplyKey = "totalSupply"
+// Define option names, stored under config~option keys so that no account ID can collide with them
+const nameOption = "name"
+const symbolOption = "symbol"
+const decimalsOption = "decimals"
+const totalSupplyOption = "totalSupply"
+const ownerOption = "owner"
 
 // Define objectType names for prefix
 const allowancePrefix = "allowance"
+const configPrefix = "config"
+
+// legacyOptions are the options the original contract stored under plain keys, in the same
+// namespace as account balances; Initialize moves them under their config~option keys
+var legacyOptions = []string{totalSupplyOption}
 
 // SmartContract provides functions for transferring tokens between accounts
 type SmartContract struct {
@@ -22,23 +33,98 @@ type SmartContract struct {
 }
 
 // event provides an organized struct for emitting events
+// Value is a base-10 integer string, since amounts may exceed the precision of JSON numbers
 type event struct {
 	From  string `json:"from"`
 	To    string `json:"to"`
-	Value int    `json:"value"`
+	Value string `json:"value"`
+}
+
+// Initialize sets the name, symbol and decimals of the token, and the submitting client as the owner
+// of the contract with the minter role. It can only be called once, and every other function
+// refuses to run until it has been called. Any client can call it, so the deployer should call it
+// right after the contract is committed. The total supply of a ledger set up by the original
+// contract is moved under its config~option key.
+// The owner can then grant and revoke the minter role with GrantMinter and RevokeMinter
+// This function triggers a RoleGranted event
+func (s *SmartContract) Initialize(ctx contractapi.TransactionContextInterface, name string, symbol string, decimals int) error {
+
+	// Get ID of submitting client identity, which becomes the owner of the contract
+	owner, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
+
+	// Check contract options are not already set, client is not authorized to change them once initialized
+	initialized, err := isInitialized(ctx)
+	if err != nil {
+		return err
+	}
+	if initialized {
+		return fmt.Errorf("contract options are already set, client is not authorized to change them")
+	}
+
+	if name == "" || symbol == "" {
+		return fmt.Errorf("token name and symbol must not be empty")
+	}
+	if decimals < 0 || decimals > 255 {
+		return fmt.Errorf("decimals must be between 0 and 255")
+	}
+
+	err = migrateLegacyOptions(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = putOption(ctx, nameOption, []byte(name))
+	if err != nil {
+		return fmt.Errorf("failed to set token name: %v", err)
+	}
+
+	err = putOption(ctx, symbolOption, []byte(symbol))
+	if err != nil {
+		return fmt.Errorf("failed to set symbol: %v", err)
+	}
+
+	err = putOption(ctx, decimalsOption, []byte(strconv.Itoa(decimals)))
+	if err != nil {
+		return fmt.Errorf("failed to set decimals: %v", err)
+	}
+
+	err = putOption(ctx, ownerOption, []byte(owner))
+	if err != nil {
+		return fmt.Errorf("failed to set owner: %v", err)
+	}
+
+	err = grantMinter(ctx, owner, principalClient, owner)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("contract initialized for token %s (%s) with %d decimals and owner %s", name, symbol, decimals, owner)
+
+	return nil
 }
 
 // Mint creates new tokens and adds them to minter's account balance
+// amount is a base-10 integer string, so amounts above 2^63 can be minted
 // This function triggers a Transfer event
-func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount int) error {
+func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount string) error {
 
-	// Check minter authorization - this sample assumes Org1 is the central banker with privilege to mint new tokens
-	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
-	if err != nil {
-		return fmt.Errorf("failed to get MSPID: %v", err)
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return err
+	}
+
+	// Check the contract is not paused
+	if err := checkNotPaused(ctx); err != nil {
+		return err
 	}
-	if clientMSPID != "Org1MSP" {
-		return fmt.Errorf("client is not authorized to mint new tokens")
+
+	// Check minter authorization against the minter registry
+	err := checkMinter(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Get ID of submitting client identity
@@ -47,55 +133,46 @@ func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount
 		return fmt.Errorf("failed to get client id: %v", err)
 	}
 
-	if amount <= 0 {
+	mintAmount, err := parseAmount(amount)
+	if err != nil {
+		return err
+	}
+	if mintAmount.Sign() <= 0 {
 		return fmt.Errorf("mint amount must be a positive integer")
 	}
 
-	currentBalanceBytes, err := ctx.GetStub().GetState(minter)
+	// If minter current balance doesn't yet exist, we'll create it with a current balance of 0
+	currentBalance, _, err := readAmount(ctx, minter)
 	if err != nil {
 		return fmt.Errorf("failed to read minter account %s from world state: %v", minter, err)
 	}
 
-	var currentBalance int
+	updatedBalance := new(big.Int).Add(currentBalance, mintAmount)
 
-	// If minter current balance doesn't yet exist, we'll create it with a current balance of 0
-	if currentBalanceBytes == nil {
-		currentBalance = 0
-	} else {
-		currentBalance, _ = strconv.Atoi(string(currentBalanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
+	err = putAmount(ctx, minter, updatedBalance)
+	if err != nil {
+		return err
 	}
 
-	updatedBalance := currentBalance + amount
-
-	err = ctx.GetStub().PutState(minter, []byte(strconv.Itoa(updatedBalance)))
+	// Update the totalSupply; if no tokens have been minted, it starts at 0
+	totalSupplyKey, err := configKey(ctx, totalSupplyOption)
 	if err != nil {
 		return err
 	}
-
-	// Update the totalSupply
-	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	totalSupply, _, err := readAmount(ctx, totalSupplyKey)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve total token supply: %v", err)
 	}
 
-	var totalSupply int
-
-	// If no tokens have been minted, initialize the totalSupply
-	if totalSupplyBytes == nil {
-		totalSupply = 0
-	} else {
-		totalSupply, _ = strconv.Atoi(string(totalSupplyBytes)) // Error handling not needed since Itoa() was used when setting the totalSupply, guaranteeing it was an integer.
-	}
-
 	// Add the mint amount to the total supply and update the state
-	totalSupply += amount
-	err = ctx.GetStub().PutState(totalSupplyKey, []byte(strconv.Itoa(totalSupply)))
+	totalSupply.Add(totalSupply, mintAmount)
+	err = putAmount(ctx, totalSupplyKey, totalSupply)
 	if err != nil {
 		return err
 	}
 
 	// Emit the Transfer event
-	transferEvent := event{"0x0", minter, amount}
+	transferEvent := event{"0x0", minter, mintAmount.String()}
 	transferEventJSON, err := json.Marshal(transferEvent)
 	if err != nil {
 		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
@@ -105,22 +182,30 @@ func (s *SmartContract) Mint(ctx contractapi.TransactionContextInterface, amount
 		return fmt.Errorf("failed to set event: %v", err)
 	}
 
-	log.Printf("minter account %s balance updated from %d to %d", minter, currentBalance, updatedBalance)
+	log.Printf("minter account %s balance updated from %s to %s", minter, currentBalance, updatedBalance)
 
 	return nil
 }
 
 // Burn redeems tokens the minter's account balance
+// The amount must not exceed the minter's balance
 // This function triggers a Transfer event
-func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, amount int) error {
+func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, amount string) error {
 
-	// Check minter authorization - this sample assumes Org1 is the central banker with privilege to burn new tokens
-	clientMSPID, err := ctx.GetClientIdentity().GetMSPID()
-	if err != nil {
-		return fmt.Errorf("failed to get MSPID: %v", err)
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return err
+	}
+
+	// Check the contract is not paused
+	if err := checkNotPaused(ctx); err != nil {
+		return err
 	}
-	if clientMSPID != "Org1MSP" {
-		return fmt.Errorf("client is not authorized to mint new tokens")
+
+	// Check minter authorization against the minter registry
+	err := checkMinter(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Get ID of submitting client identity
@@ -129,53 +214,91 @@ func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, amount
 		return fmt.Errorf("failed to get client id: %v", err)
 	}
 
-	if amount <= 0 {
-		return errors.New("burn amount must be a positive integer")
+	burnAmount, err := parseAmount(amount)
+	if err != nil {
+		return err
 	}
 
-	currentBalanceBytes, err := ctx.GetStub().GetState(minter)
+	err = burnHelper(ctx, minter, burnAmount)
 	if err != nil {
-		return fmt.Errorf("failed to read minter account %s from world state: %v", minter, err)
+		return err
 	}
 
-	var currentBalance int
+	// Emit the Transfer event
+	transferEvent := event{minter, "0x0", burnAmount.String()}
+	transferEventJSON, err := json.Marshal(transferEvent)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent("Transfer", transferEventJSON)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
 
-	// Check if minter current balance exists
-	if currentBalanceBytes == nil {
-		return errors.New("The balance does not exist")
+	return nil
+}
+
+// BurnFrom redeems tokens from the account balance on behalf of its holder, spending the allowance
+// the holder approved for the submitting client, as TransferFrom does
+// The allowance is the only authorization needed, and the amount must not exceed the allowance or the balance
+// This function triggers a Transfer event
+func (s *SmartContract) BurnFrom(ctx contractapi.TransactionContextInterface, account string, amount string) error {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return err
 	}
 
-	currentBalance, _ = strconv.Atoi(string(currentBalanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
+	// Check the contract is not paused
+	if err := checkNotPaused(ctx); err != nil {
+		return err
+	}
 
-	updatedBalance := currentBalance - amount
+	// Get ID of submitting client identity
+	spender, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return fmt.Errorf("failed to get client id: %v", err)
+	}
 
-	err = ctx.GetStub().PutState(minter, []byte(strconv.Itoa(updatedBalance)))
+	burnAmount, err := parseAmount(amount)
 	if err != nil {
 		return err
 	}
 
-	// Update the totalSupply
-	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	// Create allowanceKey
+	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{account, spender})
 	if err != nil {
-		return fmt.Errorf("failed to retrieve total token supply: %v", err)
+		return fmt.Errorf("failed to create the composite key for prefix %s: %v", allowancePrefix, err)
 	}
 
-	// If no tokens have been minted, throw error
-	if totalSupplyBytes == nil {
-		return errors.New("totalSupply does not exist")
+	// Retrieve the allowance of the spender
+	currentAllowance, _, err := readAmount(ctx, allowanceKey)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve the allowance for %s from world state: %v", allowanceKey, err)
 	}
 
-	totalSupply, _ := strconv.Atoi(string(totalSupplyBytes)) // Error handling not needed since Itoa() was used when setting the totalSupply, guaranteeing it was an integer.
+	// Check if burned amount is less than allowance
+	if currentAllowance.Cmp(burnAmount) < 0 {
+		return fmt.Errorf("spender does not have enough allowance for burn")
+	}
 
-	// Subtract the burn amount to the total supply and update the state
-	totalSupply -= amount
-	err = ctx.GetStub().PutState(totalSupplyKey, []byte(strconv.Itoa(totalSupply)))
+	err = burnHelper(ctx, account, burnAmount)
+	if err != nil {
+		return err
+	}
+
+	// Decrease the allowance
+	updatedAllowance, err := subAmount(currentAllowance, burnAmount)
+	if err != nil {
+		return err
+	}
+	err = putAmount(ctx, allowanceKey, updatedAllowance)
 	if err != nil {
 		return err
 	}
 
 	// Emit the Transfer event
-	transferEvent := event{minter, "0x0", amount}
+	transferEvent := event{account, "0x0", burnAmount.String()}
 	transferEventJSON, err := json.Marshal(transferEvent)
 	if err != nil {
 		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
@@ -185,7 +308,7 @@ func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, amount
 		return fmt.Errorf("failed to set event: %v", err)
 	}
 
-	log.Printf("minter account %s balance updated from %d to %d", minter, currentBalance, updatedBalance)
+	log.Printf("spender %s allowance updated from %s to %s", spender, currentAllowance, updatedAllowance)
 
 	return nil
 }
@@ -193,7 +316,17 @@ func (s *SmartContract) Burn(ctx contractapi.TransactionContextInterface, amount
 // Transfer transfers tokens from client account to recipient account
 // recipient account must be a valid clientID as returned by the ClientID() function
 // This function triggers a Transfer event
-func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, recipient string, amount int) error {
+func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, recipient string, amount string) error {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return err
+	}
+
+	// Check the contract is not paused
+	if err := checkNotPaused(ctx); err != nil {
+		return err
+	}
 
 	// Get ID of submitting client identity
 	clientID, err := ctx.GetClientIdentity().GetID()
@@ -201,13 +334,18 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, re
 		return fmt.Errorf("failed to get client id: %v", err)
 	}
 
-	err = transferHelper(ctx, clientID, recipient, amount)
+	transferAmount, err := parseAmount(amount)
+	if err != nil {
+		return err
+	}
+
+	err = transferHelper(ctx, clientID, recipient, transferAmount)
 	if err != nil {
 		return fmt.Errorf("failed to transfer: %v", err)
 	}
 
 	// Emit the Transfer event
-	transferEvent := event{clientID, recipient, amount}
+	transferEvent := event{clientID, recipient, transferAmount.String()}
 	transferEventJSON, err := json.Marshal(transferEvent)
 	if err != nil {
 		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
@@ -220,41 +358,53 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, re
 	return nil
 }
 
-// BalanceOf returns the balance of the given account
-func (s *SmartContract) BalanceOf(ctx contractapi.TransactionContextInterface, account string) (int, error) {
-	balanceBytes, err := ctx.GetStub().GetState(account)
-	if err != nil {
-		return 0, fmt.Errorf("failed to read from world state: %v", err)
+// BalanceOf returns the balance of the given account as a base-10 integer string
+func (s *SmartContract) BalanceOf(ctx contractapi.TransactionContextInterface, account string) (string, error) {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return "", err
 	}
-	if balanceBytes == nil {
-		return 0, fmt.Errorf("the account %s does not exist", account)
+
+	err := checkAccount(account)
+	if err != nil {
+		return "", err
 	}
 
-	balance, _ := strconv.Atoi(string(balanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
+	balance, found, err := readAmount(ctx, account)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if !found {
+		return "", fmt.Errorf("the account %s does not exist", account)
+	}
 
-	return balance, nil
+	return balance.String(), nil
 }
 
 // ClientAccountBalance returns the balance of the requesting client's account
-func (s *SmartContract) ClientAccountBalance(ctx contractapi.TransactionContextInterface) (int, error) {
+func (s *SmartContract) ClientAccountBalance(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return "", err
+	}
 
 	// Get ID of submitting client identity
 	clientID, err := ctx.GetClientIdentity().GetID()
 	if err != nil {
-		return 0, fmt.Errorf("failed to get client id: %v", err)
+		return "", fmt.Errorf("failed to get client id: %v", err)
 	}
 
-	balanceBytes, err := ctx.GetStub().GetState(clientID)
+	balance, found, err := readAmount(ctx, clientID)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read from world state: %v", err)
+		return "", fmt.Errorf("failed to read from world state: %v", err)
 	}
-	if balanceBytes == nil {
-		return 0, fmt.Errorf("the account %s does not exist", clientID)
+	if !found {
+		return "", fmt.Errorf("the account %s does not exist", clientID)
 	}
 
-	balance, _ := strconv.Atoi(string(balanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
-
-	return balance, nil
+	return balance.String(), nil
 }
 
 // ClientAccountID returns the id of the requesting client's account
@@ -262,6 +412,11 @@ func (s *SmartContract) ClientAccountBalance(ctx contractapi.TransactionContextI
 // Users can use this function to get their own account id, which they can then give to others as the payment address
 func (s *SmartContract) ClientAccountID(ctx contractapi.TransactionContextInterface) (string, error) {
 
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return "", err
+	}
+
 	// Get ID of submitting client identity
 	clientAccountID, err := ctx.GetClientIdentity().GetID()
 	if err != nil {
@@ -271,33 +426,97 @@ func (s *SmartContract) ClientAccountID(ctx contractapi.TransactionContextInterf
 	return clientAccountID, nil
 }
 
-// TotalSupply returns the total token supply
-func (s *SmartContract) TotalSupply(ctx contractapi.TransactionContextInterface) (int, error) {
+// TotalSupply returns the total token supply as a base-10 integer string
+func (s *SmartContract) TotalSupply(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return "", err
+	}
 
-	// Retrieve total supply of tokens from state of smart contract
-	totalSupplyBytes, err := ctx.GetStub().GetState(totalSupplyKey)
+	// Retrieve total supply of tokens from state of smart contract; if no tokens have been minted, it is 0
+	totalSupplyKey, err := configKey(ctx, totalSupplyOption)
 	if err != nil {
-		return 0, fmt.Errorf("failed to retrieve total token supply: %v", err)
+		return "", err
 	}
+	totalSupply, _, err := readAmount(ctx, totalSupplyKey)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve total token supply: %v", err)
+	}
+
+	log.Printf("TotalSupply: %s tokens", totalSupply)
+
+	return totalSupply.String(), nil
+}
 
-	var totalSupply int
+// Name returns a descriptive name for fungible tokens in this contract
+func (s *SmartContract) Name(ctx contractapi.TransactionContextInterface) (string, error) {
 
-	// If no tokens have been minted, return 0
-	if totalSupplyBytes == nil {
-		totalSupply = 0
-	} else {
-		totalSupply, _ = strconv.Atoi(string(totalSupplyBytes)) // Error handling not needed since Itoa() was used when setting the totalSupply, guaranteeing it was an integer.
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return "", err
 	}
 
-	log.Printf("TotalSupply: %d tokens", totalSupply)
+	bytes, err := getOption(ctx, nameOption)
+	if err != nil {
+		return "", fmt.Errorf("failed to get token name: %v", err)
+	}
 
-	return totalSupply, nil
+	return string(bytes), nil
+}
+
+// Symbol returns an abbreviated name for fungible tokens in this contract
+func (s *SmartContract) Symbol(ctx contractapi.TransactionContextInterface) (string, error) {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return "", err
+	}
+
+	bytes, err := getOption(ctx, symbolOption)
+	if err != nil {
+		return "", fmt.Errorf("failed to get token symbol: %v", err)
+	}
+
+	return string(bytes), nil
+}
+
+// Decimals returns the number of decimal places used to display token amounts,
+// e.g. a balance of 1050 with 2 decimals is displayed as 10.50
+func (s *SmartContract) Decimals(ctx contractapi.TransactionContextInterface) (int, error) {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return 0, err
+	}
+
+	bytes, err := getOption(ctx, decimalsOption)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get token decimals: %v", err)
+	}
+
+	decimals, err := strconv.Atoi(string(bytes))
+	if err != nil {
+		return 0, fmt.Errorf("decimals %q is not an integer: %v", string(bytes), err)
+	}
+
+	return decimals, nil
 }
 
 // Approve allows the spender to withdraw from the calling client's token account
 // The spender can withdraw multiple times if necessary, up to the value amount
 // This function triggers an Approval event
-func (s *SmartContract) Approve(ctx contractapi.TransactionContextInterface, spender string, value int) error {
+func (s *SmartContract) Approve(ctx contractapi.TransactionContextInterface, spender string, value string) error {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return err
+	}
+
+	// Check the contract is not paused
+	if err := checkNotPaused(ctx); err != nil {
+		return err
+	}
 
 	// Get ID of submitting client identity
 	owner, err := ctx.GetClientIdentity().GetID()
@@ -305,6 +524,14 @@ func (s *SmartContract) Approve(ctx contractapi.TransactionContextInterface, spe
 		return fmt.Errorf("failed to get client id: %v", err)
 	}
 
+	approvedValue, err := parseAmount(value)
+	if err != nil {
+		return err
+	}
+	if approvedValue.Sign() < 0 {
+		return fmt.Errorf("allowance cannot be negative")
+	}
+
 	// Create allowanceKey
 	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{owner, spender})
 	if err != nil {
@@ -312,13 +539,13 @@ func (s *SmartContract) Approve(ctx contractapi.TransactionContextInterface, spe
 	}
 
 	// Update the state of the smart contract by adding the allowanceKey and value
-	err = ctx.GetStub().PutState(allowanceKey, []byte(strconv.Itoa(value)))
+	err = putAmount(ctx, allowanceKey, approvedValue)
 	if err != nil {
 		return fmt.Errorf("failed to update state of smart contract for key %s: %v", allowanceKey, err)
 	}
 
 	// Emit the Approval event
-	approvalEvent := event{owner, spender, value}
+	approvalEvent := event{owner, spender, approvedValue.String()}
 	approvalEventJSON, err := json.Marshal(approvalEvent)
 	if err != nil {
 		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
@@ -328,43 +555,49 @@ func (s *SmartContract) Approve(ctx contractapi.TransactionContextInterface, spe
 		return fmt.Errorf("failed to set event: %v", err)
 	}
 
-	log.Printf("client %s approved a withdrawal allowance of %d for spender %s", owner, value, spender)
+	log.Printf("client %s approved a withdrawal allowance of %s for spender %s", owner, approvedValue, spender)
 
 	return nil
 }
 
 // Allowance returns the amount still available for the spender to withdraw from the owner
-func (s *SmartContract) Allowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (int, error) {
+func (s *SmartContract) Allowance(ctx contractapi.TransactionContextInterface, owner string, spender string) (string, error) {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return "", err
+	}
 
 	// Create allowanceKey
 	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{owner, spender})
 	if err != nil {
-		return 0, fmt.Errorf("failed to create the composite key for prefix %s: %v", allowancePrefix, err)
+		return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", allowancePrefix, err)
 	}
 
-	// Read the allowance amount from the world state
-	allowanceBytes, err := ctx.GetStub().GetState(allowanceKey)
+	// Read the allowance amount from the world state; if no current allowance, it is 0
+	allowance, _, err := readAmount(ctx, allowanceKey)
 	if err != nil {
-		return 0, fmt.Errorf("failed to read allowance for %s from world state: %v", allowanceKey, err)
-	}
-
-	var allowance int
-
-	// If no current allowance, set allowance to 0
-	if allowanceBytes == nil {
-		allowance = 0
-	} else {
-		allowance, err = strconv.Atoi(string(allowanceBytes)) // Error handling not needed since Itoa() was used when setting the totalSupply, guaranteeing it was an integer.
+		return "", fmt.Errorf("failed to read allowance for %s from world state: %v", allowanceKey, err)
 	}
 
-	log.Printf("The allowance left for spender %s to withdraw from owner %s: %d", spender, owner, allowance)
+	log.Printf("The allowance left for spender %s to withdraw from owner %s: %s", spender, owner, allowance)
 
-	return allowance, nil
+	return allowance.String(), nil
 }
 
 // TransferFrom transfers the value amount from the "from" address to the "to" address
 // This function triggers a Transfer event
-func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface, from string, to string, value int) error {
+func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface, from string, to string, value string) error {
+
+	// Check if contract has been initialized first
+	if err := checkInitialized(ctx); err != nil {
+		return err
+	}
+
+	// Check the contract is not paused
+	if err := checkNotPaused(ctx); err != nil {
+		return err
+	}
 
 	// Get ID of submitting client identity
 	spender, err := ctx.GetClientIdentity().GetID()
@@ -372,6 +605,11 @@ func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface
 		return fmt.Errorf("failed to get client id: %v", err)
 	}
 
+	transferValue, err := parseAmount(value)
+	if err != nil {
+		return err
+	}
+
 	// Create allowanceKey
 	allowanceKey, err := ctx.GetStub().CreateCompositeKey(allowancePrefix, []string{from, spender})
 	if err != nil {
@@ -379,34 +617,34 @@ func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface
 	}
 
 	// Retrieve the allowance of the spender
-	currentAllowanceBytes, err := ctx.GetStub().GetState(allowanceKey)
+	currentAllowance, _, err := readAmount(ctx, allowanceKey)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve the allowance for %s from world state: %v", allowanceKey, err)
 	}
 
-	var currentAllowance int
-	currentAllowance, _ = strconv.Atoi(string(currentAllowanceBytes)) // Error handling not needed since Itoa() was used when setting the totalSupply, guaranteeing it was an integer.
-
 	// Check if transferred value is less than allowance
-	if currentAllowance < value {
+	if currentAllowance.Cmp(transferValue) < 0 {
 		return fmt.Errorf("spender does not have enough allowance for transfer")
 	}
 
 	// Initiate the transfer
-	err = transferHelper(ctx, from, to, value)
+	err = transferHelper(ctx, from, to, transferValue)
 	if err != nil {
 		return fmt.Errorf("failed to transfer: %v", err)
 	}
 
 	// Decrease the allowance
-	updatedAllowance := currentAllowance - value
-	err = ctx.GetStub().PutState(allowanceKey, []byte(strconv.Itoa(updatedAllowance)))
+	updatedAllowance, err := subAmount(currentAllowance, transferValue)
+	if err != nil {
+		return err
+	}
+	err = putAmount(ctx, allowanceKey, updatedAllowance)
 	if err != nil {
 		return err
 	}
 
 	// Emit the Transfer event
-	transferEvent := event{from, to, value}
+	transferEvent := event{from, to, transferValue.String()}
 	transferEventJSON, err := json.Marshal(transferEvent)
 	if err != nil {
 		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
@@ -416,68 +654,236 @@ func (s *SmartContract) TransferFrom(ctx contractapi.TransactionContextInterface
 		return fmt.Errorf("failed to set event: %v", err)
 	}
 
-	log.Printf("spender %s allowance updated from %d to %d", spender, currentAllowance, updatedAllowance)
+	log.Printf("spender %s allowance updated from %s to %s", spender, currentAllowance, updatedAllowance)
 
 	return nil
 }
 
 // Helper Functions
 
+// configKey returns the config~option key the option is stored under
+func configKey(ctx contractapi.TransactionContextInterface, option string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(configPrefix, []string{option})
+	if err != nil {
+		return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", configPrefix, err)
+	}
+
+	return key, nil
+}
+
+// getOption reads the value of the option, which is nil if the option is not set
+func getOption(ctx contractapi.TransactionContextInterface, option string) ([]byte, error) {
+	key, err := configKey(ctx, option)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.GetStub().GetState(key)
+}
+
+// putOption sets the value of the option
+func putOption(ctx contractapi.TransactionContextInterface, option string, value []byte) error {
+	key, err := configKey(ctx, option)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, value)
+}
+
+// isInitialized returns whether Initialize has set the contract options
+func isInitialized(ctx contractapi.TransactionContextInterface) (bool, error) {
+	tokenName, err := getOption(ctx, nameOption)
+	if err != nil {
+		return false, fmt.Errorf("failed to get token name: %v", err)
+	}
+
+	return tokenName != nil, nil
+}
+
+// checkInitialized returns an error unless Initialize has set the contract options
+func checkInitialized(ctx contractapi.TransactionContextInterface) error {
+	initialized, err := isInitialized(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to check if contract is already initialized: %v", err)
+	}
+	if !initialized {
+		return fmt.Errorf("contract options need to be set before calling any function, call Initialize() to initialize contract")
+	}
+
+	return nil
+}
+
+// migrateLegacyOptions moves the options the original contract stored under plain keys to their
+// config~option keys, and deletes the plain keys
+func migrateLegacyOptions(ctx contractapi.TransactionContextInterface) error {
+	for _, option := range legacyOptions {
+		value, err := ctx.GetStub().GetState(option)
+		if err != nil {
+			return fmt.Errorf("failed to read legacy option %s from world state: %v", option, err)
+		}
+		if value == nil {
+			continue
+		}
+
+		err = putOption(ctx, option, value)
+		if err != nil {
+			return fmt.Errorf("failed to migrate legacy option %s: %v", option, err)
+		}
+		err = ctx.GetStub().DelState(option)
+		if err != nil {
+			return fmt.Errorf("failed to delete legacy option %s: %v", option, err)
+		}
+
+		log.Printf("legacy option %s moved under its config key", option)
+	}
+
+	return nil
+}
+
+// checkAccount returns an error unless the account ID can be used as the key of a balance
+// Composite keys, such as options and allowances, start with a null character, and the original
+// contract stored the total supply under a plain key
+func checkAccount(account string) error {
+	if account == "" {
+		return errors.New("account must not be empty")
+	}
+	if strings.HasPrefix(account, "\x00") {
+		return fmt.Errorf("account %q must not start with a null character", account)
+	}
+	for _, option := range legacyOptions {
+		if account == option {
+			return fmt.Errorf("account %s is reserved", account)
+		}
+	}
+
+	return nil
+}
+
+// burnHelper is a helper function that removes tokens from the account balance and the total supply
+// Dependant functions include Burn and BurnFrom
+func burnHelper(ctx contractapi.TransactionContextInterface, account string, amount *big.Int) error {
+
+	if amount.Sign() <= 0 {
+		return errors.New("burn amount must be a positive integer")
+	}
+
+	err := checkAccount(account)
+	if err != nil {
+		return err
+	}
+
+	currentBalance, found, err := readAmount(ctx, account)
+	if err != nil {
+		return fmt.Errorf("failed to read account %s from world state: %v", account, err)
+	}
+
+	// Check if account current balance exists
+	if !found {
+		return errors.New("the balance does not exist")
+	}
+
+	// Check if the balance covers the burn amount
+	if currentBalance.Cmp(amount) < 0 {
+		return fmt.Errorf("burn amount %s exceeds the balance %s of account %s", amount, currentBalance, account)
+	}
+
+	updatedBalance, err := subAmount(currentBalance, amount)
+	if err != nil {
+		return err
+	}
+
+	// Update the totalSupply
+	totalSupplyKey, err := configKey(ctx, totalSupplyOption)
+	if err != nil {
+		return err
+	}
+	totalSupply, found, err := readAmount(ctx, totalSupplyKey)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve total token supply: %v", err)
+	}
+
+	// If no tokens have been minted, throw error
+	if !found {
+		return errors.New("totalSupply does not exist")
+	}
+
+	// Subtract the burn amount to the total supply
+	totalSupply, err = subAmount(totalSupply, amount)
+	if err != nil {
+		return fmt.Errorf("failed to update total token supply: %v", err)
+	}
+
+	err = putAmount(ctx, account, updatedBalance)
+	if err != nil {
+		return err
+	}
+
+	err = putAmount(ctx, totalSupplyKey, totalSupply)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("account %s balance updated from %s to %s", account, currentBalance, updatedBalance)
+
+	return nil
+}
+
 // transferHelper is a helper function that transfers tokens from the "from" address to the "to" address
 // Dependant functions include Transfer and TransferFrom
-func transferHelper(ctx contractapi.TransactionContextInterface, from string, to string, value int) error {
+func transferHelper(ctx contractapi.TransactionContextInterface, from string, to string, value *big.Int) error {
 
 	if from == to {
 		return fmt.Errorf("cannot transfer to and from same client account")
 	}
 
-	if value < 0 { // transfer of 0 is allowed in ERC-20, so just validate against negative amounts
+	err := checkAccount(from)
+	if err != nil {
+		return err
+	}
+	err = checkAccount(to)
+	if err != nil {
+		return err
+	}
+
+	if value.Sign() < 0 { // transfer of 0 is allowed in ERC-20, so just validate against negative amounts
 		return fmt.Errorf("transfer amount cannot be negative")
 	}
 
-	fromCurrentBalanceBytes, err := ctx.GetStub().GetState(from)
+	fromCurrentBalance, found, err := readAmount(ctx, from)
 	if err != nil {
 		return fmt.Errorf("failed to read client account %s from world state: %v", from, err)
 	}
 
-	if fromCurrentBalanceBytes == nil {
+	if !found {
 		return fmt.Errorf("client account %s has no balance", from)
 	}
 
-	fromCurrentBalance, _ := strconv.Atoi(string(fromCurrentBalanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
-
-	if fromCurrentBalance < value {
+	fromUpdatedBalance, err := subAmount(fromCurrentBalance, value)
+	if err != nil {
 		return fmt.Errorf("client account %s has insufficient funds", from)
 	}
 
-	toCurrentBalanceBytes, err := ctx.GetStub().GetState(to)
+	// If recipient current balance doesn't yet exist, we'll create it with a current balance of 0
+	toCurrentBalance, _, err := readAmount(ctx, to)
 	if err != nil {
 		return fmt.Errorf("failed to read recipient account %s from world state: %v", to, err)
 	}
 
-	var toCurrentBalance int
-	// If recipient current balance doesn't yet exist, we'll create it with a current balance of 0
-	if toCurrentBalanceBytes == nil {
-		toCurrentBalance = 0
-	} else {
-		toCurrentBalance, _ = strconv.Atoi(string(toCurrentBalanceBytes)) // Error handling not needed since Itoa() was used when setting the account balance, guaranteeing it was an integer.
-	}
-
-	fromUpdatedBalance := fromCurrentBalance - value
-	toUpdatedBalance := toCurrentBalance + value
+	toUpdatedBalance := new(big.Int).Add(toCurrentBalance, value)
 
-	err = ctx.GetStub().PutState(from, []byte(strconv.Itoa(fromUpdatedBalance)))
+	err = putAmount(ctx, from, fromUpdatedBalance)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(to, []byte(strconv.Itoa(toUpdatedBalance)))
+	err = putAmount(ctx, to, toUpdatedBalance)
 	if err != nil {
 		return err
 	}
 
-	log.Printf("client %s balance updated from %d to %d", from, fromCurrentBalance, fromUpdatedBalance)
-	log.Printf("recipient %s balance updated from %d to %d", to, toCurrentBalance, toUpdatedBalance)
+	log.Printf("client %s balance updated from %s to %s", from, fromCurrentBalance, fromUpdatedBalance)
+	log.Printf("recipient %s balance updated from %s to %s", to, toCurrentBalance, toUpdatedBalance)
 
 	return nil
 }
@@ -0,0 +1,302 @@
+package chaincode_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"testing"
+
+	"token-erc-20/chaincode"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var Stub *MockStub
+
+// txCount numbers the transactions of the tests, since the mock stub needs a
+// transaction ID per invocation
+var txCount int
+
+func TestMain(m *testing.M) {
+	setup()
+	code := m.Run()
+	os.Exit(code)
+}
+
+func setup() {
+	log.SetOutput(ioutil.Discard)
+}
+
+func NewStub() {
+	scc, err := contractapi.NewChaincode(new(chaincode.SmartContract))
+	if err != nil {
+		log.Println("NewChaincode failed", err)
+		os.Exit(0)
+	}
+	Stub = NewMockStub("token", scc)
+}
+
+// AsOwner makes the following invocations come from the client that
+// initializes the contract in the tests, and returns its client ID
+func AsOwner() string {
+	return Stub.SetCreator("Org1MSP", "owner")
+}
+
+// As makes the following invocations come from the named client of Org2MSP,
+// and returns its client ID
+func As(name string) string {
+	return Stub.SetCreator("Org2MSP", name)
+}
+
+// NewInitializedStub returns a stub whose contract was initialized by the
+// owner, who is the creator of the following invocations
+func NewInitializedStub(t *testing.T) string {
+	NewStub()
+	owner := AsOwner()
+	_, err := Invoke("Initialize", "Taiwan Dollar Token", "TWDT", "2")
+	require.NoError(t, err)
+	return owner
+}
+
+// Invoke submits a transaction and returns its payload, or its error message
+// as an error
+func Invoke(function string, args ...string) (string, error) {
+	callArgs := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		callArgs = append(callArgs, []byte(arg))
+	}
+	txCount++
+	res := Stub.MockInvoke(fmt.Sprintf("tx%d", txCount), callArgs)
+	if res.Status != shim.OK {
+		return "", errors.New(res.Message)
+	}
+	return string(res.Payload), nil
+}
+
+// AssertEvent asserts that the last invocation set the named event
+func AssertEvent(t *testing.T, name string, payload string) {
+	require.NotNil(t, Stub.Event)
+	assert.Equal(t, name, Stub.Event.EventName)
+	assert.JSONEq(t, payload, string(Stub.Event.Payload))
+}
+
+// PutLedgerState writes the value under key outside of any contract
+// function, as an earlier version of the contract would have
+func PutLedgerState(key string, value string) {
+	txCount++
+	Stub.MockTransactionStart(fmt.Sprintf("tx%d", txCount))
+	err := Stub.PutState(key, []byte(value))
+	if err != nil {
+		panic(err)
+	}
+	Stub.MockTransactionEnd(fmt.Sprintf("tx%d", txCount))
+}
+
+func Test_Initialize(t *testing.T) {
+	fmt.Println("Test_Initialize-----------------")
+	NewStub()
+
+	AsOwner()
+	_, err := Invoke("TotalSupply")
+	assert.EqualError(t, err, "contract options need to be set before calling any function, call Initialize() to initialize contract")
+	_, err = Invoke("Initialize", "", "TWDT", "2")
+	assert.EqualError(t, err, "token name and symbol must not be empty")
+	_, err = Invoke("Initialize", "Taiwan Dollar Token", "TWDT", "256")
+	assert.EqualError(t, err, "decimals must be between 0 and 255")
+
+	owner := AsOwner()
+	_, err = Invoke("Initialize", "Taiwan Dollar Token", "TWDT", "2")
+	require.NoError(t, err)
+	AssertEvent(t, "RoleGranted", fmt.Sprintf(`{"role":"minter","principalType":"client","principal":%q,"sender":%q}`, owner, owner))
+
+	result, err := Invoke("Name")
+	require.NoError(t, err)
+	assert.Equal(t, "Taiwan Dollar Token", result)
+	result, err = Invoke("Symbol")
+	require.NoError(t, err)
+	assert.Equal(t, "TWDT", result)
+	result, err = Invoke("Decimals")
+	require.NoError(t, err)
+	assert.Equal(t, "2", result)
+	result, err = Invoke("TotalSupply")
+	require.NoError(t, err)
+	assert.Equal(t, "0", result)
+
+	// Neither the owner nor any other client can initialize the contract again
+	_, err = Invoke("Initialize", "Other Token", "OTH", "0")
+	assert.EqualError(t, err, "contract options are already set, client is not authorized to change them")
+	Stub.SetCreator("Org1MSP", "admin")
+	_, err = Invoke("Initialize", "Other Token", "OTH", "0")
+	assert.EqualError(t, err, "contract options are already set, client is not authorized to change them")
+	As("alice")
+	_, err = Invoke("Initialize", "Other Token", "OTH", "0")
+	assert.EqualError(t, err, "contract options are already set, client is not authorized to change them")
+}
+
+func Test_InitializeByAnyMSP(t *testing.T) {
+	fmt.Println("Test_InitializeByAnyMSP-----------------")
+	NewStub()
+
+	// The owner is whoever initializes the contract first, whatever its MSP
+	alice := As("alice")
+	_, err := Invoke("Initialize", "Taiwan Dollar Token", "TWDT", "2")
+	require.NoError(t, err)
+
+	result, err := Invoke("Owner")
+	require.NoError(t, err)
+	assert.Equal(t, alice, result)
+	result, err = Invoke("IsMinter", "client", alice)
+	require.NoError(t, err)
+	assert.Equal(t, "true", result)
+
+	AsOwner()
+	_, err = Invoke("Mint", "100")
+	assert.EqualError(t, err, "client is not authorized to mint or burn tokens")
+}
+
+func Test_InitializeLegacyLedger(t *testing.T) {
+	fmt.Println("Test_InitializeLegacyLedger-----------------")
+	NewStub()
+
+	// A ledger set up by the original contract has its total supply under a
+	// plain key, next to the balances
+	owner := AsOwner()
+	PutLedgerState("totalSupply", "500")
+	PutLedgerState(owner, "500")
+
+	_, err := Invoke("Initialize", "Taiwan Dollar Token", "TWDT", "2")
+	require.NoError(t, err)
+	AssertEvent(t, "RoleGranted", fmt.Sprintf(`{"role":"minter","principalType":"client","principal":%q,"sender":%q}`, owner, owner))
+
+	result, err := Invoke("Owner")
+	require.NoError(t, err)
+	assert.Equal(t, owner, result)
+	result, err = Invoke("TotalSupply")
+	require.NoError(t, err)
+	assert.Equal(t, "500", result)
+	result, err = Invoke("ClientAccountBalance")
+	require.NoError(t, err)
+	assert.Equal(t, "500", result)
+	assert.NotContains(t, Stub.State, "totalSupply")
+}
+
+func Test_ReservedAccounts(t *testing.T) {
+	fmt.Println("Test_ReservedAccounts-----------------")
+	NewInitializedStub(t)
+	_, err := Invoke("Mint", "1000")
+	require.NoError(t, err)
+
+	// Options cannot be overwritten by transferring to their keys
+	for _, account := range []string{"totalSupply", "\x00config\x00decimals\x00"} {
+		_, err = Invoke("Transfer", account, "5")
+		assert.Error(t, err, account)
+		_, err = Invoke("BalanceOf", account)
+		assert.Error(t, err, account)
+	}
+	_, err = Invoke("Transfer", "", "5")
+	assert.EqualError(t, err, "failed to transfer: account must not be empty")
+
+	// Accounts named after options are ordinary accounts
+	_, err = Invoke("Transfer", "decimals", "5")
+	require.NoError(t, err)
+	result, err := Invoke("BalanceOf", "decimals")
+	require.NoError(t, err)
+	assert.Equal(t, "5", result)
+
+	result, err = Invoke("Decimals")
+	require.NoError(t, err)
+	assert.Equal(t, "2", result)
+	result, err = Invoke("TotalSupply")
+	require.NoError(t, err)
+	assert.Equal(t, "1000", result)
+	result, err = Invoke("ClientAccountBalance")
+	require.NoError(t, err)
+	assert.Equal(t, "995", result)
+}
+
+func Test_Burn(t *testing.T) {
+	fmt.Println("Test_Burn-----------------")
+	owner := NewInitializedStub(t)
+	_, err := Invoke("Mint", "100")
+	require.NoError(t, err)
+
+	_, err = Invoke("Burn", "150")
+	assert.EqualError(t, err, fmt.Sprintf("burn amount 150 exceeds the balance 100 of account %s", owner))
+	_, err = Invoke("Burn", "0")
+	assert.EqualError(t, err, "burn amount must be a positive integer")
+
+	_, err = Invoke("Burn", "40")
+	require.NoError(t, err)
+	AssertEvent(t, "Transfer", fmt.Sprintf(`{"from":%q,"to":"0x0","value":"40"}`, owner))
+	result, err := Invoke("ClientAccountBalance")
+	require.NoError(t, err)
+	assert.Equal(t, "60", result)
+	result, err = Invoke("TotalSupply")
+	require.NoError(t, err)
+	assert.Equal(t, "60", result)
+
+	As("alice")
+	_, err = Invoke("Burn", "10")
+	assert.EqualError(t, err, "client is not authorized to mint or burn tokens")
+}
+
+func Test_BurnFrom(t *testing.T) {
+	fmt.Println("Test_BurnFrom-----------------")
+	owner := NewInitializedStub(t)
+	alice := As("alice")
+	bob := As("bob")
+	AsOwner()
+	_, err := Invoke("Mint", "100")
+	require.NoError(t, err)
+	_, err = Invoke("Transfer", alice, "80")
+	require.NoError(t, err)
+
+	As("alice")
+	_, err = Invoke("Approve", owner, "50")
+	require.NoError(t, err)
+	_, err = Invoke("Approve", bob, "50")
+	require.NoError(t, err)
+
+	AsOwner()
+	_, err = Invoke("BurnFrom", alice, "60")
+	assert.EqualError(t, err, "spender does not have enough allowance for burn")
+	_, err = Invoke("BurnFrom", alice, "30")
+	require.NoError(t, err)
+	AssertEvent(t, "Transfer", fmt.Sprintf(`{"from":%q,"to":"0x0","value":"30"}`, alice))
+
+	result, err := Invoke("BalanceOf", alice)
+	require.NoError(t, err)
+	assert.Equal(t, "50", result)
+	result, err = Invoke("Allowance", alice, owner)
+	require.NoError(t, err)
+	assert.Equal(t, "20", result)
+	result, err = Invoke("TotalSupply")
+	require.NoError(t, err)
+	assert.Equal(t, "70", result)
+
+	// The allowance does not let the spender burn more than the balance
+	As("alice")
+	_, err = Invoke("Approve", owner, "500")
+	require.NoError(t, err)
+	AsOwner()
+	_, err = Invoke("BurnFrom", alice, "60")
+	assert.EqualError(t, err, fmt.Sprintf("burn amount 60 exceeds the balance 50 of account %s", alice))
+
+	// Spenders without the minter role can burn within their allowance
+	As("bob")
+	_, err = Invoke("BurnFrom", alice, "10")
+	require.NoError(t, err)
+	AssertEvent(t, "Transfer", fmt.Sprintf(`{"from":%q,"to":"0x0","value":"10"}`, alice))
+	result, err = Invoke("Allowance", alice, bob)
+	require.NoError(t, err)
+	assert.Equal(t, "40", result)
+	_, err = Invoke("Mint", "10")
+	assert.EqualError(t, err, "client is not authorized to mint or burn tokens")
+	_, err = Invoke("BurnFrom", owner, "10")
+	assert.EqualError(t, err, "spender does not have enough allowance for burn")
+}
@@ -0,0 +1,305 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Roles are read from the "role" attribute of the client certificate, which
+// may list several roles separated by commas, or granted on the ledger to
+// every client of an MSP with GrantRole. Any CA can put any role in the
+// certificates it issues, so a certificate role only counts once an admin has
+// trusted it for the MSP of the client with TrustCertificateRole.
+const roleAttribute = "role"
+
+const (
+	roleAdmin     = "admin"
+	roleBankAdmin = "bank-admin"
+	roleTeller    = "teller"
+)
+
+// functionRoles declares which roles may call each restricted contract
+// function. Functions not listed here may be called by any client. An admin
+// can override the roles of a function on the ledger with SetFunctionRoles.
+var functionRoles = map[string][]string{
+	"InitLedger":              {roleAdmin},
+	"MigrateKeys":             {roleAdmin},
+	"SetFunctionRoles":        {roleAdmin},
+	"GrantRole":               {roleAdmin},
+	"RevokeRole":              {roleAdmin},
+	"TrustCertificateRole":    {roleAdmin},
+	"DistrustCertificateRole": {roleAdmin},
+	"SetOverdraftProtection":  {roleAdmin},
+	"SetFxRate":               {roleAdmin},
+	"CreateBank":              {roleAdmin, roleBankAdmin},
+	"UpdateBank":              {roleAdmin, roleBankAdmin},
+	"DeactivateBank":          {roleAdmin, roleBankAdmin},
+	"CreateUser":              {roleAdmin, roleBankAdmin, roleTeller},
+	"UpdateUser":              {roleAdmin, roleBankAdmin, roleTeller},
+	"DeleteUser":              {roleAdmin, roleBankAdmin},
+	"RestoreUser":             {roleAdmin, roleBankAdmin},
+	"PurgeUser":               {roleAdmin},
+	"CreateTransaction":       {roleBankAdmin, roleTeller},
+	"BatchCreateUsers":        {roleAdmin, roleBankAdmin, roleTeller},
+	"BatchCreateTransactions": {roleBankAdmin, roleTeller},
+	"TransferBetweenUsers":    {roleBankAdmin, roleTeller},
+	"ReverseTransaction":      {roleAdmin, roleBankAdmin},
+}
+
+// FunctionRoles lists the roles allowed to call a contract function
+type FunctionRoles struct {
+	Function string   `json:"function"`
+	Roles    []string `json:"roles"`
+}
+
+// RoleGrant gives a role to every client of an MSP
+type RoleGrant struct {
+	MSPID string `json:"msp_id"`
+	Role  string `json:"role"`
+}
+
+// CertificateRoleTrust lets the clients of an MSP hold a role through the
+// role attribute of their certificate
+type CertificateRoleTrust struct {
+	MSPID string `json:"msp_id"`
+	Role  string `json:"role"`
+}
+
+// AccessBootstrap records the MSP granted the admin role by the first InitLedger
+type AccessBootstrap struct {
+	MSPID string `json:"msp_id"`
+}
+
+// Define objectType names for prefix
+const functionRolesPrefix = "acl"
+const roleGrantPrefix = "mspRole"
+const certificateRolePrefix = "certRole"
+
+const accessBootstrapName = "access"
+
+// SetFunctionRoles replaces the roles allowed to call function
+func (s *SmartContract) SetFunctionRoles(ctx contractapi.TransactionContextInterface, function string, roles []string) error {
+	fmt.Println("function SetFunctionRoles")
+	err := checkAccess(ctx, "SetFunctionRoles")
+	if err != nil {
+		return err
+	}
+	if _, ok := functionRoles[function]; !ok {
+		return fmt.Errorf("the function %s does not enforce roles", function)
+	}
+	if len(roles) == 0 {
+		return fmt.Errorf("at least one role must be allowed to call %s", function)
+	}
+
+	key, err := createKey(ctx, functionRolesPrefix, function)
+	if err != nil {
+		return err
+	}
+	return putJSON(ctx, key, FunctionRoles{Function: function, Roles: roles})
+}
+
+// GetFunctionRoles returns the roles allowed to call function
+func (s *SmartContract) GetFunctionRoles(ctx contractapi.TransactionContextInterface, function string) (*FunctionRoles, error) {
+	fmt.Println("function GetFunctionRoles")
+	if _, ok := functionRoles[function]; !ok {
+		return nil, fmt.Errorf("the function %s does not enforce roles", function)
+	}
+	return readFunctionRoles(ctx, function)
+}
+
+// GrantRole gives role to every client of the MSP
+func (s *SmartContract) GrantRole(ctx contractapi.TransactionContextInterface, mspId string, role string) error {
+	fmt.Println("function GrantRole")
+	err := checkAccess(ctx, "GrantRole")
+	if err != nil {
+		return err
+	}
+	return grantRole(ctx, mspId, role)
+}
+
+// RevokeRole takes back a role granted to the MSP
+func (s *SmartContract) RevokeRole(ctx contractapi.TransactionContextInterface, mspId string, role string) error {
+	fmt.Println("function RevokeRole")
+	err := checkAccess(ctx, "RevokeRole")
+	if err != nil {
+		return err
+	}
+
+	key, err := createKey(ctx, roleGrantPrefix, mspId, role)
+	if err != nil {
+		return err
+	}
+	grantJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if grantJson == nil {
+		return fmt.Errorf("the role %s is not granted to %s", role, mspId)
+	}
+	return ctx.GetStub().DelState(key)
+}
+
+// TrustCertificateRole honours role in the certificates issued by the MSP
+func (s *SmartContract) TrustCertificateRole(ctx contractapi.TransactionContextInterface, mspId string, role string) error {
+	fmt.Println("function TrustCertificateRole")
+	err := checkAccess(ctx, "TrustCertificateRole")
+	if err != nil {
+		return err
+	}
+	return trustCertificateRole(ctx, mspId, role)
+}
+
+// DistrustCertificateRole stops honouring role in the certificates issued by
+// the MSP
+func (s *SmartContract) DistrustCertificateRole(ctx contractapi.TransactionContextInterface, mspId string, role string) error {
+	fmt.Println("function DistrustCertificateRole")
+	err := checkAccess(ctx, "DistrustCertificateRole")
+	if err != nil {
+		return err
+	}
+
+	key, err := createKey(ctx, certificateRolePrefix, mspId, role)
+	if err != nil {
+		return err
+	}
+	trustJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if trustJson == nil {
+		return fmt.Errorf("the certificate role %s of %s is not trusted", role, mspId)
+	}
+	return ctx.GetStub().DelState(key)
+}
+
+// bootstrapAccess grants the admin role to the MSP of the client calling
+// InitLedger for the first time, and trusts the built-in roles in the
+// certificates it issues, so that roles can be managed afterwards. Later
+// calls must come from an admin.
+func bootstrapAccess(ctx contractapi.TransactionContextInterface) error {
+	key, err := createKey(ctx, configPrefix, accessBootstrapName)
+	if err != nil {
+		return err
+	}
+	bootstrapJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if bootstrapJson != nil {
+		return checkAccess(ctx, "InitLedger")
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSPID: %v", err)
+	}
+	err = grantRole(ctx, mspId, roleAdmin)
+	if err != nil {
+		return err
+	}
+	for _, role := range []string{roleAdmin, roleBankAdmin, roleTeller} {
+		err = trustCertificateRole(ctx, mspId, role)
+		if err != nil {
+			return err
+		}
+	}
+	return putJSON(ctx, key, AccessBootstrap{MSPID: mspId})
+}
+
+// checkAccess fails unless the client holds one of the roles allowed to call
+// function, either granted to its MSP or in its certificate if the MSP is
+// trusted with that certificate role
+func checkAccess(ctx contractapi.TransactionContextInterface, function string) error {
+	allowed, err := readFunctionRoles(ctx, function)
+	if err != nil {
+		return err
+	}
+
+	mspId, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return fmt.Errorf("failed to get MSPID: %v", err)
+	}
+	attribute, _, err := ctx.GetClientIdentity().GetAttributeValue(roleAttribute)
+	if err != nil {
+		return fmt.Errorf("failed to get client attribute %s: %v", roleAttribute, err)
+	}
+	certificateRoles := map[string]bool{}
+	for _, role := range strings.Split(attribute, ",") {
+		certificateRoles[strings.TrimSpace(role)] = true
+	}
+
+	for _, role := range allowed.Roles {
+		if certificateRoles[role] {
+			key, err := createKey(ctx, certificateRolePrefix, mspId, role)
+			if err != nil {
+				return err
+			}
+			trustJson, err := ctx.GetStub().GetState(key)
+			if err != nil {
+				return fmt.Errorf("failed to read from world state: %v", err)
+			}
+			if trustJson != nil {
+				return nil
+			}
+		}
+		key, err := createKey(ctx, roleGrantPrefix, mspId, role)
+		if err != nil {
+			return err
+		}
+		grantJson, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if grantJson != nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("the client of %s is not authorized to call %s, which requires one of the roles %s", mspId, function, strings.Join(allowed.Roles, ", "))
+}
+
+func readFunctionRoles(ctx contractapi.TransactionContextInterface, function string) (*FunctionRoles, error) {
+	key, err := createKey(ctx, functionRolesPrefix, function)
+	if err != nil {
+		return nil, err
+	}
+	rolesJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if rolesJson == nil {
+		return &FunctionRoles{Function: function, Roles: functionRoles[function]}, nil
+	}
+
+	var roles FunctionRoles
+	err = json.Unmarshal(rolesJson, &roles)
+	if err != nil {
+		return nil, err
+	}
+	return &roles, nil
+}
+
+func grantRole(ctx contractapi.TransactionContextInterface, mspId string, role string) error {
+	if mspId == "" || role == "" {
+		return fmt.Errorf("the MSP ID and role must not be empty")
+	}
+	key, err := createKey(ctx, roleGrantPrefix, mspId, role)
+	if err != nil {
+		return err
+	}
+	return putJSON(ctx, key, RoleGrant{MSPID: mspId, Role: role})
+}
+
+func trustCertificateRole(ctx contractapi.TransactionContextInterface, mspId string, role string) error {
+	if mspId == "" || role == "" {
+		return fmt.Errorf("the MSP ID and role must not be empty")
+	}
+	key, err := createKey(ctx, certificateRolePrefix, mspId, role)
+	if err != nil {
+		return err
+	}
+	return putJSON(ctx, key, CertificateRoleTrust{MSPID: mspId, Role: role})
+}
@@ -0,0 +1,194 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// UserBalance is the running total of a user's transactions in one currency
+type UserBalance struct {
+	UserId   string `json:"user_id"`
+	Currency string `json:"currency"`
+	Balance  string `json:"balance"`
+	// SkippedTransactions lists the transactions GetUserBalanceIn left out
+	// because their amount, currency or date cannot be parsed
+	SkippedTransactions []string `json:"skipped_transactions,omitempty" metadata:",optional"`
+}
+
+// OverdraftPolicy controls whether debits may take a balance below zero
+type OverdraftPolicy struct {
+	Protected bool `json:"protected"`
+}
+
+// Define objectType names for prefix
+const balancePrefix = "balance"
+const configPrefix = "config"
+
+const overdraftPolicyName = "overdraft"
+
+// GetUserBalance returns the user's balance in currency, which is zero if the
+// user has no transactions in it
+func (s *SmartContract) GetUserBalance(ctx contractapi.TransactionContextInterface, userId string, currency string) (*UserBalance, error) {
+	fmt.Println("function GetUserBalance")
+	exists, err := s.UserExists(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the user %s does not exist", userId)
+	}
+	currency, err = parseCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, _, err := readBalance(ctx, userId, currency)
+	if err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
+
+// GetUserBalances returns the user's balance in every currency it has transactions in
+func (s *SmartContract) GetUserBalances(ctx contractapi.TransactionContextInterface, userId string) ([]*UserBalance, error) {
+	fmt.Println("function GetUserBalances")
+	exists, err := s.UserExists(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the user %s does not exist", userId)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(balancePrefix, []string{userId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	balances := []*UserBalance{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var balance UserBalance
+		err = json.Unmarshal(queryResponse.Value, &balance)
+		if err != nil {
+			return nil, err
+		}
+		balances = append(balances, &balance)
+	}
+
+	return balances, nil
+}
+
+// SetOverdraftProtection turns on or off the rule rejecting debits that
+// would take a balance below zero
+func (s *SmartContract) SetOverdraftProtection(ctx contractapi.TransactionContextInterface, protected bool) error {
+	fmt.Println("function SetOverdraftProtection")
+	err := checkAccess(ctx, "SetOverdraftProtection")
+	if err != nil {
+		return err
+	}
+	key, err := createKey(ctx, configPrefix, overdraftPolicyName)
+	if err != nil {
+		return err
+	}
+	return putJSON(ctx, key, OverdraftPolicy{Protected: protected})
+}
+
+// GetOverdraftProtection reports whether debits below zero are rejected
+func (s *SmartContract) GetOverdraftProtection(ctx contractapi.TransactionContextInterface) (bool, error) {
+	fmt.Println("function GetOverdraftProtection")
+	policy, err := readOverdraftPolicy(ctx)
+	if err != nil {
+		return false, err
+	}
+	return policy.Protected, nil
+}
+
+// readBalance returns the user's balance in currency and its amount in minor units
+func readBalance(ctx contractapi.TransactionContextInterface, userId string, currency string) (*UserBalance, int64, error) {
+	key, err := createKey(ctx, balancePrefix, userId, currency)
+	if err != nil {
+		return nil, 0, err
+	}
+	balanceJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if balanceJson == nil {
+		return &UserBalance{UserId: userId, Currency: currency, Balance: formatAmount(0, currency)}, 0, nil
+	}
+
+	var balance UserBalance
+	err = json.Unmarshal(balanceJson, &balance)
+	if err != nil {
+		return nil, 0, err
+	}
+	minorUnits, err := parseDecimal(balance.Balance, currency)
+	if err != nil {
+		return nil, 0, fmt.Errorf("the balance of user %s in %s is corrupted: %v", userId, currency, err)
+	}
+	return &balance, minorUnits, nil
+}
+
+// updateBalance adds minorUnits of currency to the user's balance. Debits that
+// would take the balance below zero are rejected while overdraft protection is on.
+func updateBalance(ctx contractapi.TransactionContextInterface, userId string, currency string, minorUnits int64) (*UserBalance, error) {
+	balance, current, err := readBalance(ctx, userId, currency)
+	if err != nil {
+		return nil, err
+	}
+	if (minorUnits > 0 && current > math.MaxInt64-minorUnits) || (minorUnits < 0 && current < math.MinInt64-minorUnits) {
+		return nil, fmt.Errorf("the balance of user %s in %s would overflow", userId, currency)
+	}
+	updated := current + minorUnits
+
+	if minorUnits < 0 && updated < 0 {
+		policy, err := readOverdraftPolicy(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if policy.Protected {
+			return nil, fmt.Errorf("insufficient funds: the balance of user %s is %s %s", userId, balance.Balance, currency)
+		}
+	}
+
+	balance.Balance = formatAmount(updated, currency)
+	key, err := createKey(ctx, balancePrefix, userId, currency)
+	if err != nil {
+		return nil, err
+	}
+	err = putJSON(ctx, key, balance)
+	if err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
+
+func readOverdraftPolicy(ctx contractapi.TransactionContextInterface) (*OverdraftPolicy, error) {
+	key, err := createKey(ctx, configPrefix, overdraftPolicyName)
+	if err != nil {
+		return nil, err
+	}
+	policyJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+
+	var policy OverdraftPolicy
+	if policyJson == nil {
+		return &policy, nil
+	}
+	err = json.Unmarshal(policyJson, &policy)
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
@@ -0,0 +1,160 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Bank statuses. Inactive banks cannot process new transactions.
+const (
+	bankActive   = "active"
+	bankInactive = "inactive"
+)
+
+// swiftCodePattern matches an 8 or 11 character SWIFT/BIC code: bank code,
+// country code, location code and optional branch code
+var swiftCodePattern = regexp.MustCompile(`^[A-Z]{4}([A-Z]{2})[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+
+var countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+
+// UpdateBank replaces the name, SWIFT/BIC code and country of a bank
+func (s *SmartContract) UpdateBank(ctx contractapi.TransactionContextInterface, bankId string, name string, swiftCode string, country string) error {
+	fmt.Println("function UpdateBank")
+	err := checkAccess(ctx, "UpdateBank")
+	if err != nil {
+		return err
+	}
+	bank, err := s.GetBankByID(ctx, bankId)
+	if err != nil {
+		return err
+	}
+	err = validateBank(name, swiftCode, country)
+	if err != nil {
+		return err
+	}
+
+	bank.Name = name
+	bank.SwiftCode = swiftCode
+	bank.Country = country
+	err = putBank(ctx, bank)
+	if err != nil {
+		return err
+	}
+	return emitEvents(ctx, "UpdateBank", EntityEvent{Type: BankUpdated, EntityID: bankId, Data: bank})
+}
+
+// GetAllBanks returns every bank, active or inactive
+func (s *SmartContract) GetAllBanks(ctx contractapi.TransactionContextInterface) ([]*Bank, error) {
+	fmt.Println("function GetAllBanks")
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(bankPrefix, []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	banks := []*Bank{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var bank Bank
+		err = json.Unmarshal(queryResponse.Value, &bank)
+		if err != nil {
+			return nil, err
+		}
+		if bank.Status == "" {
+			bank.Status = bankActive
+		}
+		banks = append(banks, &bank)
+	}
+
+	return banks, nil
+}
+
+// DeactivateBank stops the bank from processing new transactions. Its
+// existing transactions are kept.
+func (s *SmartContract) DeactivateBank(ctx contractapi.TransactionContextInterface, bankId string) error {
+	fmt.Println("function DeactivateBank")
+	err := checkAccess(ctx, "DeactivateBank")
+	if err != nil {
+		return err
+	}
+	bank, err := s.GetBankByID(ctx, bankId)
+	if err != nil {
+		return err
+	}
+	if bank.Status == bankInactive {
+		return fmt.Errorf("the bank %s is already deactivated", bankId)
+	}
+
+	bank.Status = bankInactive
+	err = putBank(ctx, bank)
+	if err != nil {
+		return err
+	}
+	return emitEvents(ctx, "DeactivateBank", EntityEvent{Type: BankDeactivated, EntityID: bankId, Data: bank})
+}
+
+// newBank returns a new active bank, failing if the bank exists or its
+// fields are invalid
+func newBank(ctx contractapi.TransactionContextInterface, bankId string, name string, swiftCode string, country string) (*Bank, error) {
+	if bankId == "" {
+		return nil, &ValidationError{Field: "id", Value: bankId, Reason: "must not be empty"}
+	}
+	key, err := bankKey(ctx, bankId)
+	if err != nil {
+		return nil, err
+	}
+	bankJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if bankJson != nil {
+		return nil, fmt.Errorf("the bank %s already exists", bankId)
+	}
+	err = validateBank(name, swiftCode, country)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bank{
+		ID:        bankId,
+		Name:      name,
+		SwiftCode: swiftCode,
+		Country:   country,
+		Status:    bankActive,
+	}, nil
+}
+
+// validateBank checks the name, SWIFT/BIC code and country of a bank. The
+// country must match the country code within the SWIFT/BIC code.
+func validateBank(name string, swiftCode string, country string) error {
+	if name == "" {
+		return &ValidationError{Field: "name", Value: name, Reason: "must not be empty"}
+	}
+	if !countryPattern.MatchString(country) {
+		return &ValidationError{Field: "country", Value: country, Reason: "not an ISO 3166-1 alpha-2 country code"}
+	}
+	matches := swiftCodePattern.FindStringSubmatch(swiftCode)
+	if matches == nil {
+		return &ValidationError{Field: "swift_code", Value: swiftCode, Reason: "not an 8 or 11 character SWIFT/BIC code"}
+	}
+	if matches[1] != country {
+		return &ValidationError{Field: "swift_code", Value: swiftCode, Reason: fmt.Sprintf("does not belong to a bank in %s", country)}
+	}
+	return nil
+}
+
+// putBank stores the bank under its bank~id key
+func putBank(ctx contractapi.TransactionContextInterface, bank *Bank) error {
+	key, err := bankKey(ctx, bank.ID)
+	if err != nil {
+		return err
+	}
+	return putJSON(ctx, key, bank)
+}
@@ -0,0 +1,426 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxBatchSize bounds the items of one batch, keeping the proposal and its
+// write set well within the limits of the ordering service
+const maxBatchSize = 500
+
+// usersTransientKey is the transient map entry holding the PIIInput of each
+// user of BatchCreateUsers, keyed by user id
+const usersTransientKey = "users"
+
+// BatchItemResult reports the outcome of one item of a batch. Index is the
+// position of the item in the batch and ID its user id or transaction hash.
+type BatchItemResult struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id"`
+	Status string `json:"status,omitempty" metadata:",optional"`
+	Error  string `json:"error,omitempty" metadata:",optional"`
+}
+
+// BatchResult lists the outcome of every item of an applied batch
+type BatchResult struct {
+	Items []BatchItemResult `json:"items"`
+}
+
+// BatchError rejects a whole batch, listing the items that failed validation
+type BatchError struct {
+	Items []BatchItemResult `json:"items"`
+}
+
+func (e *BatchError) Error() string {
+	errorJson, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("%d items of the batch are invalid", len(e.Items))
+	}
+	return string(errorJson)
+}
+
+// BatchCreateUsers creates the users whose ids are listed in usersJSON, e.g.
+// ["1", "2"], from the PIIInput passed for each id in the transient map under
+// "users", e.g. {"1": {"name": ..., "email": ..., "salt": ...}}. Every user
+// is validated before any is written, and the batch is rejected with a
+// BatchError if any user is invalid.
+func (s *SmartContract) BatchCreateUsers(ctx contractapi.TransactionContextInterface, usersJSON string) (*BatchResult, error) {
+	fmt.Println("function BatchCreateUsers")
+	err := checkAccess(ctx, "BatchCreateUsers")
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	err = json.Unmarshal([]byte(usersJSON), &ids)
+	if err != nil {
+		return nil, &ValidationError{Field: "users", Value: usersJSON, Reason: "not a JSON array of user ids"}
+	}
+	err = validateBatchSize("users", len(ids))
+	if err != nil {
+		return nil, err
+	}
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the transient map: %v", err)
+	}
+	inputsJson, ok := transientMap[usersTransientKey]
+	if !ok {
+		return nil, fmt.Errorf("the %s entry must be passed in the transient map", usersTransientKey)
+	}
+	var inputs map[string]PIIInput
+	err = json.Unmarshal(inputsJson, &inputs)
+	if err != nil {
+		return nil, &ValidationError{Field: usersTransientKey, Value: "", Reason: "not a JSON object of name, email and salt by user id"}
+	}
+
+	invalid := []BatchItemResult{}
+	seenIds := map[string]bool{}
+	seenEmails := map[string]string{}
+	for index, id := range ids {
+		err := s.validateBatchUser(ctx, id, inputs, seenIds, seenEmails)
+		if err != nil {
+			invalid = append(invalid, BatchItemResult{Index: index, ID: id, Error: err.Error()})
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, &BatchError{Items: invalid}
+	}
+
+	result := BatchResult{Items: []BatchItemResult{}}
+	events := []EntityEvent{}
+	for index, id := range ids {
+		input := inputs[id]
+		err = reserveEmail(ctx, input.Email, id)
+		if err != nil {
+			return nil, err
+		}
+		user := User{
+			ID: id,
+		}
+		err = putUserPII(ctx, &user, &UserPII{Name: input.Name, Email: input.Email, Salt: input.Salt})
+		if err != nil {
+			return nil, err
+		}
+		result.Items = append(result.Items, BatchItemResult{Index: index, ID: id, Status: transactionCreated})
+		events = append(events, EntityEvent{Type: UserCreated, EntityID: id, Data: user})
+	}
+
+	err = emitEvents(ctx, "BatchCreateUsers", events...)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// BatchCreateTransactions records the transactions listed in txnsJSON, a JSON
+// array of transactions with user_id, hash, amount, currency, date and
+// bank_id. Like CreateTransaction, transactions already recorded with the
+// same payload are reported as duplicates. Every transaction is validated,
+// including overdraft protection on the running balances, before any is
+// written, and the batch is rejected with a BatchError if any is invalid.
+func (s *SmartContract) BatchCreateTransactions(ctx contractapi.TransactionContextInterface, txnsJSON string) (*BatchResult, error) {
+	fmt.Println("function BatchCreateTransactions")
+	err := checkAccess(ctx, "BatchCreateTransactions")
+	if err != nil {
+		return nil, err
+	}
+	var inputs []Transaction
+	err = json.Unmarshal([]byte(txnsJSON), &inputs)
+	if err != nil {
+		return nil, &ValidationError{Field: "transactions", Value: txnsJSON, Reason: "not a JSON array of transactions"}
+	}
+	err = validateBatchSize("transactions", len(inputs))
+	if err != nil {
+		return nil, err
+	}
+	policy, err := readOverdraftPolicy(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The peer does not read back the writes of the transaction being
+	// endorsed, so balances and bank counts are accumulated here and each
+	// is written once
+	batch := transactionBatch{
+		contract:  s,
+		protected: policy.Protected,
+		seen:      map[string]bool{},
+		users:     map[string]error{},
+		banks:     map[string]*Bank{},
+		balances:  map[balanceKey]int64{},
+		start:     map[balanceKey]int64{},
+		stats:     map[bankStatsKey]*BankDailyStats{},
+	}
+	result := BatchResult{Items: []BatchItemResult{}}
+	invalid := []BatchItemResult{}
+	transactions := []*Transaction{}
+	for index, input := range inputs {
+		transaction, status, err := batch.add(ctx, input)
+		if err != nil {
+			invalid = append(invalid, BatchItemResult{Index: index, ID: input.Hash, Error: err.Error()})
+			continue
+		}
+		result.Items = append(result.Items, BatchItemResult{Index: index, ID: input.Hash, Status: status})
+		if status == transactionCreated {
+			transactions = append(transactions, transaction)
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, &BatchError{Items: invalid}
+	}
+	if len(transactions) == 0 {
+		return &result, nil
+	}
+
+	events := []EntityEvent{}
+	for _, transaction := range transactions {
+		err = putTransaction(ctx, transaction)
+		if err != nil {
+			return nil, err
+		}
+		hashKey, err := transactionHashKey(ctx, transaction.Hash)
+		if err != nil {
+			return nil, err
+		}
+		err = putJSON(ctx, hashKey, TransactionHashMapUserId{UserId: transaction.UserId})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, EntityEvent{Type: TransactionCreated, EntityID: transaction.Hash, Data: transaction})
+	}
+	for _, key := range batch.balanceOrder {
+		balance, err := updateBalance(ctx, key.userId, key.currency, batch.balances[key]-batch.start[key])
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, EntityEvent{Type: BalanceUpdated, EntityID: key.userId + "/" + key.currency, Data: balance})
+	}
+	for _, bankId := range batch.bankOrder {
+		bank := batch.banks[bankId]
+		err = putBank(ctx, bank)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, EntityEvent{Type: BankUpdated, EntityID: bankId, Data: bank})
+	}
+	for _, key := range batch.statsOrder {
+		err = putBankStats(ctx, batch.stats[key])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = emitEvents(ctx, "BatchCreateTransactions", events...)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// validateBatchSize checks that a batch has between 1 and maxBatchSize items
+func validateBatchSize(field string, size int) error {
+	if size == 0 {
+		return &ValidationError{Field: field, Value: "", Reason: "must not be empty"}
+	}
+	if size > maxBatchSize {
+		return &ValidationError{Field: field, Value: fmt.Sprint(size), Reason: fmt.Sprintf("must not list more than %d items", maxBatchSize)}
+	}
+	return nil
+}
+
+// validateBatchUser checks one user of BatchCreateUsers against the ledger
+// and the users before it in the batch
+func (s *SmartContract) validateBatchUser(ctx contractapi.TransactionContextInterface, id string, inputs map[string]PIIInput, seenIds map[string]bool, seenEmails map[string]string) error {
+	if id == "" {
+		return &ValidationError{Field: "id", Value: id, Reason: "must not be empty"}
+	}
+	if seenIds[id] {
+		return fmt.Errorf("the user %s is listed more than once", id)
+	}
+	seenIds[id] = true
+	exists, err := s.UserExists(ctx, id)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("the user %s already exists", id)
+	}
+	err = checkNotPurged(ctx, id)
+	if err != nil {
+		return err
+	}
+	input, ok := inputs[id]
+	if !ok {
+		return fmt.Errorf("the personal data of user %s must be passed in the transient map", id)
+	}
+	err = validateSalt(input.Salt)
+	if err != nil {
+		return err
+	}
+	err = parseEmail(input.Email)
+	if err != nil {
+		return err
+	}
+	email := strings.ToLower(input.Email)
+	if other, ok := seenEmails[email]; ok {
+		return fmt.Errorf("the email %s is also listed for user %s", input.Email, other)
+	}
+	seenEmails[email] = id
+	owner, err := readEmailOwner(ctx, input.Email)
+	if err != nil {
+		return err
+	}
+	if owner != "" {
+		return fmt.Errorf("the email %s is already used by user %s", input.Email, owner)
+	}
+	return nil
+}
+
+// balanceKey identifies the balance of a user in one currency
+type balanceKey struct {
+	userId   string
+	currency string
+}
+
+// bankStatsKey identifies the statistics of a bank in one currency on one day
+type bankStatsKey struct {
+	bankId   string
+	day      string
+	currency string
+}
+
+// transactionBatch validates the transactions of BatchCreateTransactions one
+// by one, keeping the state they would leave behind
+type transactionBatch struct {
+	contract  *SmartContract
+	protected bool
+	// seen holds the hashes of the batch
+	seen map[string]bool
+	// users caches whether each user may transact
+	users map[string]error
+	// banks holds the banks of the batch with their updated counts
+	banks     map[string]*Bank
+	bankOrder []string
+	// balances holds the running balances in minor units, and start their
+	// values on the ledger
+	balances     map[balanceKey]int64
+	start        map[balanceKey]int64
+	balanceOrder []balanceKey
+	// stats holds the updated daily statistics of the banks
+	stats      map[bankStatsKey]*BankDailyStats
+	statsOrder []bankStatsKey
+}
+
+// add validates input like CreateTransaction does and applies it to the
+// running state of the batch. It returns the transaction to record and
+// whether it is created or a duplicate.
+func (b *transactionBatch) add(ctx contractapi.TransactionContextInterface, input Transaction) (*Transaction, string, error) {
+	err := validateHash(input.Hash)
+	if err != nil {
+		return nil, "", err
+	}
+	if b.seen[input.Hash] {
+		return nil, "", fmt.Errorf("the transaction %s is listed more than once", input.Hash)
+	}
+	b.seen[input.Hash] = true
+	currency, err := parseCurrency(input.Currency)
+	if err != nil {
+		return nil, "", err
+	}
+	minorUnits, err := parseAmount(input.Amount, currency)
+	if err != nil {
+		return nil, "", err
+	}
+	transactionDate, err := parseDate(ctx, input.Date)
+	if err != nil {
+		return nil, "", err
+	}
+
+	transaction := Transaction{
+		UserId:   input.UserId,
+		Hash:     input.Hash,
+		Amount:   formatAmount(minorUnits, currency),
+		Currency: currency,
+		Date:     transactionDate.Format(time.RFC3339),
+		BankId:   input.BankId,
+	}
+	existing, err := readTransactionByHash(ctx, input.Hash)
+	if err != nil {
+		return nil, "", err
+	}
+	if existing != nil {
+		if !isRetry(transaction, existing, input.Date == "") {
+			return nil, "", fmt.Errorf("the transaction %s already exists with a different payload", input.Hash)
+		}
+		return existing, transactionDuplicate, nil
+	}
+
+	userErr, ok := b.users[input.UserId]
+	if !ok {
+		_, userErr = b.contract.GetUser(ctx, input.UserId)
+		b.users[input.UserId] = userErr
+	}
+	if userErr != nil {
+		return nil, "", userErr
+	}
+	bank, ok := b.banks[input.BankId]
+	if !ok {
+		bank, err = b.contract.GetBankByID(ctx, input.BankId)
+		if err != nil {
+			return nil, "", err
+		}
+		if bank.Status != bankActive {
+			return nil, "", fmt.Errorf("the bank %s is deactivated", input.BankId)
+		}
+	}
+
+	key := balanceKey{userId: input.UserId, currency: currency}
+	current, tracked := b.balances[key]
+	if !tracked {
+		_, current, err = readBalance(ctx, input.UserId, currency)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	if b.protected && minorUnits < 0 && current+minorUnits < 0 {
+		return nil, "", fmt.Errorf("insufficient funds: the balance of user %s would be %s %s", input.UserId, formatAmount(current+minorUnits, currency), currency)
+	}
+	day, err := transactionDay(&transaction)
+	if err != nil {
+		return nil, "", err
+	}
+	statsKey := bankStatsKey{bankId: input.BankId, day: day, currency: currency}
+	stats, trackedStats := b.stats[statsKey]
+	if !trackedStats {
+		stats, err = readBankStats(ctx, input.BankId, day, currency)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	updatedStats := *stats
+	err = addToBankStats(&updatedStats, transaction.Amount)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !tracked {
+		b.start[key] = current
+		b.balanceOrder = append(b.balanceOrder, key)
+	}
+	b.balances[key] = current + minorUnits
+	if _, ok := b.banks[input.BankId]; !ok {
+		b.banks[input.BankId] = bank
+		b.bankOrder = append(b.bankOrder, input.BankId)
+	}
+	bank.TransactionCount++
+	if !trackedStats {
+		b.statsOrder = append(b.statsOrder, statsKey)
+	}
+	b.stats[statsKey] = &updatedStats
+	return &transaction, transactionCreated, nil
+}
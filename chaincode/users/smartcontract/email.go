@@ -0,0 +1,105 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// EmailMapUserId is the email uniqueness index entry pointing to the user
+// that uses the email. Emails are personal data, so the index is kept in the
+// private data collection.
+type EmailMapUserId struct {
+	UserId string `json:"user_id"`
+}
+
+// Define objectType names for prefix
+const emailPrefix = "email"
+
+// GetUserByEmail returns the personal data of the active user with the given email
+func (s *SmartContract) GetUserByEmail(ctx contractapi.TransactionContextInterface, email string) (*UserPII, error) {
+	fmt.Println("function GetUserByEmail")
+	userId, err := readEmailOwner(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if userId == "" {
+		return nil, fmt.Errorf("no user has the email %s", email)
+	}
+	return s.GetUserPII(ctx, userId)
+}
+
+// parseEmail checks that email is a bare address such as john.lee@g.com
+func parseEmail(email string) error {
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email || !strings.Contains(email[strings.LastIndex(email, "@")+1:], ".") {
+		return &ValidationError{Field: "email", Value: "", Reason: "not a valid email address"}
+	}
+	return nil
+}
+
+// emailKey returns the email~address key of the uniqueness index. Addresses
+// are compared case-insensitively.
+func emailKey(ctx contractapi.TransactionContextInterface, email string) (string, error) {
+	return createKey(ctx, emailPrefix, strings.ToLower(email))
+}
+
+// readEmailOwner returns the id of the user using email, or "" if it is free
+func readEmailOwner(ctx contractapi.TransactionContextInterface, email string) (string, error) {
+	key, err := emailKey(ctx, email)
+	if err != nil {
+		return "", err
+	}
+	emailMapJson, err := ctx.GetStub().GetPrivateData(piiCollection, key)
+	if err != nil {
+		return "", fmt.Errorf("failed to read from private data: %v", err)
+	}
+	if emailMapJson == nil {
+		return "", nil
+	}
+
+	var emailMapUserId EmailMapUserId
+	err = json.Unmarshal(emailMapJson, &emailMapUserId)
+	if err != nil {
+		return "", err
+	}
+	return emailMapUserId.UserId, nil
+}
+
+// reserveEmail records that the user uses email, failing if another user,
+// active or deactivated, already does
+func reserveEmail(ctx contractapi.TransactionContextInterface, email string, userId string) error {
+	owner, err := readEmailOwner(ctx, email)
+	if err != nil {
+		return err
+	}
+	if owner != "" && owner != userId {
+		return fmt.Errorf("the email %s is already used by user %s", email, owner)
+	}
+
+	key, err := emailKey(ctx, email)
+	if err != nil {
+		return err
+	}
+	return putPrivateJSON(ctx, key, EmailMapUserId{UserId: userId})
+}
+
+// releaseEmail removes email from the uniqueness index if the user holds it
+func releaseEmail(ctx contractapi.TransactionContextInterface, email string, userId string) error {
+	owner, err := readEmailOwner(ctx, email)
+	if err != nil {
+		return err
+	}
+	if owner != userId {
+		return nil
+	}
+
+	key, err := emailKey(ctx, email)
+	if err != nil {
+		return err
+	}
+	return delPrivateKey(ctx, key)
+}
@@ -0,0 +1,76 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// eventSchemaVersion is the version of the EventEnvelope schema. It changes
+// whenever a field is removed or its meaning changes.
+const eventSchemaVersion = "1.0"
+
+// Entity event types
+const (
+	UserCreated            = "UserCreated"
+	UserUpdated            = "UserUpdated"
+	UserDeactivated        = "UserDeactivated"
+	UserRestored           = "UserRestored"
+	UserDeleted            = "UserDeleted"
+	TransactionHashDeleted = "TransactionHashDeleted"
+	TransactionCreated     = "TransactionCreated"
+	TransferCreated        = "TransferCreated"
+	TransactionReversed    = "TransactionReversed"
+	BalanceUpdated         = "BalanceUpdated"
+	BankCreated            = "BankCreated"
+	BankUpdated            = "BankUpdated"
+	BankDeactivated        = "BankDeactivated"
+	FxRateSet              = "FxRateSet"
+)
+
+// EventEnvelope is the payload of the chaincode event emitted by a contract
+// function. Fabric keeps a single event per transaction, so the envelope lists
+// every entity the function changed, in the order they were changed. The
+// chaincode event is named after the type of the first entity event.
+type EventEnvelope struct {
+	Version   string        `json:"version"`
+	Function  string        `json:"function"`
+	TxID      string        `json:"tx_id"`
+	Timestamp string        `json:"timestamp"`
+	Events    []EntityEvent `json:"events"`
+}
+
+// EntityEvent describes the change of one entity. Data holds the entity as
+// stored after the change and is omitted for deletions.
+type EntityEvent struct {
+	Type     string      `json:"type"`
+	EntityID string      `json:"entity_id"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// emitEvents sets the chaincode event of the transaction to an envelope of events
+func emitEvents(ctx contractapi.TransactionContextInterface, function string, events ...EntityEvent) error {
+	timestamp, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	envelope := EventEnvelope{
+		Version:   eventSchemaVersion,
+		Function:  function,
+		TxID:      ctx.GetStub().GetTxID(),
+		Timestamp: timestamp.Format(time.RFC3339Nano),
+		Events:    events,
+	}
+	envelopeJson, err := json.Marshal(envelope)
+	if err != nil {
+		return fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	err = ctx.GetStub().SetEvent(events[0].Type, envelopeJson)
+	if err != nil {
+		return fmt.Errorf("failed to set event: %v", err)
+	}
+	return nil
+}
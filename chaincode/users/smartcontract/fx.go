@@ -0,0 +1,275 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// FxRate converts one unit of From into Rate units of To from EffectiveDate,
+// a YYYY-MM-DD date in UTC, until the next rate of the pair takes effect
+type FxRate struct {
+	From          string `json:"from"`
+	To            string `json:"to"`
+	Rate          string `json:"rate"`
+	EffectiveDate string `json:"effective_date"`
+}
+
+// Define objectType names for prefix
+const fxRatePrefix = "fxrate"
+
+// dayLayout is the layout of the UTC days that rates and bank statistics are
+// keyed by. Days in this layout sort chronologically, so the keys are stored
+// in order.
+const dayLayout = "2006-01-02"
+
+var ratePattern = regexp.MustCompile(`^[0-9]+(\.[0-9]+)?$`)
+
+// SetFxRate records the rate converting from into to, effective from
+// effectiveDate. Setting a rate again for the same date replaces it.
+func (s *SmartContract) SetFxRate(ctx contractapi.TransactionContextInterface, from string, to string, rate string, effectiveDate string) error {
+	fmt.Println("function SetFxRate")
+	err := checkAccess(ctx, "SetFxRate")
+	if err != nil {
+		return err
+	}
+	from, err = parseCurrency(from)
+	if err != nil {
+		return err
+	}
+	to, err = parseCurrency(to)
+	if err != nil {
+		return err
+	}
+	if from == to {
+		return &ValidationError{Field: "to", Value: to, Reason: "must differ from the source currency"}
+	}
+	_, err = parseRate(rate)
+	if err != nil {
+		return err
+	}
+	_, err = time.Parse(dayLayout, effectiveDate)
+	if err != nil {
+		return &ValidationError{Field: "effective_date", Value: effectiveDate, Reason: "not a YYYY-MM-DD date"}
+	}
+
+	fxRate := FxRate{From: from, To: to, Rate: rate, EffectiveDate: effectiveDate}
+	key, err := createKey(ctx, fxRatePrefix, from, to, effectiveDate)
+	if err != nil {
+		return err
+	}
+	err = putJSON(ctx, key, fxRate)
+	if err != nil {
+		return err
+	}
+	return emitEvents(ctx, "SetFxRate", EntityEvent{Type: FxRateSet, EntityID: from + "/" + to + "/" + effectiveDate, Data: fxRate})
+}
+
+// GetFxRate returns the rate converting from into to in effect on date, a
+// YYYY-MM-DD or RFC 3339 date, or on the transaction date if it is empty.
+// Without a rate for the pair, the inverse of the rate of the opposite pair
+// is returned.
+func (s *SmartContract) GetFxRate(ctx contractapi.TransactionContextInterface, from string, to string, date string) (*FxRate, error) {
+	fmt.Println("function GetFxRate")
+	from, err := parseCurrency(from)
+	if err != nil {
+		return nil, err
+	}
+	to, err = parseCurrency(to)
+	if err != nil {
+		return nil, err
+	}
+	day, err := parseFxDate(ctx, date)
+	if err != nil {
+		return nil, err
+	}
+	fxRate, _, err := readFxRate(ctx, from, to, day)
+	if err != nil {
+		return nil, err
+	}
+	return fxRate, nil
+}
+
+// GetUserBalanceIn returns the user's holdings in all currencies converted
+// into currency. Each transaction is converted at the rate in effect on its
+// date, and the total is rounded half away from zero to the minor unit.
+// Legacy transactions whose amount, currency or date cannot be parsed are
+// left out and listed in SkippedTransactions.
+func (s *SmartContract) GetUserBalanceIn(ctx contractapi.TransactionContextInterface, userId string, currency string) (*UserBalance, error) {
+	fmt.Println("function GetUserBalanceIn")
+	exists, err := s.UserExists(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the user %s does not exist", userId)
+	}
+	currency, err = parseCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(transactionPrefix, []string{userId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	balance := &UserBalance{UserId: userId, Currency: currency}
+	total := new(big.Rat)
+	rates := map[string]*big.Rat{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var transaction Transaction
+		err = json.Unmarshal(queryResponse.Value, &transaction)
+		if err != nil {
+			return nil, err
+		}
+		// Legacy entries MigrateKeys could not normalize are left out and listed
+		if _, ok := currencyPrecision[transaction.Currency]; !ok {
+			balance.SkippedTransactions = append(balance.SkippedTransactions, transaction.Hash)
+			continue
+		}
+		minorUnits, err := parseDecimal(transaction.Amount, transaction.Currency)
+		if err != nil {
+			balance.SkippedTransactions = append(balance.SkippedTransactions, transaction.Hash)
+			continue
+		}
+		day, err := transactionDay(&transaction)
+		if err != nil {
+			balance.SkippedTransactions = append(balance.SkippedTransactions, transaction.Hash)
+			continue
+		}
+
+		rate, ok := rates[transaction.Currency+"/"+day]
+		if !ok {
+			_, rate, err = readFxRate(ctx, transaction.Currency, currency, day)
+			if err != nil {
+				return nil, err
+			}
+			rates[transaction.Currency+"/"+day] = rate
+		}
+		amount := new(big.Rat).SetFrac(big.NewInt(minorUnits), pow10(currencyPrecision[transaction.Currency]))
+		total.Add(total, amount.Mul(amount, rate))
+	}
+
+	minorUnits, err := roundMinorUnits(total, currency)
+	if err != nil {
+		return nil, fmt.Errorf("the balance of user %s in %s is out of range", userId, currency)
+	}
+	balance.Balance = formatAmount(minorUnits, currency)
+	return balance, nil
+}
+
+// readFxRate returns the rate converting from into to in effect on day, with
+// its value. It is the latest rate set for the pair or for the opposite pair,
+// inverted, on or before day; the pair itself wins when both were set on the
+// same day. Currencies convert into themselves at a rate of 1.
+func readFxRate(ctx contractapi.TransactionContextInterface, from string, to string, day string) (*FxRate, *big.Rat, error) {
+	if from == to {
+		return &FxRate{From: from, To: to, Rate: "1", EffectiveDate: day}, big.NewRat(1, 1), nil
+	}
+	fxRate, err := readLatestFxRate(ctx, from, to, day)
+	if err != nil {
+		return nil, nil, err
+	}
+	inverse, err := readLatestFxRate(ctx, to, from, day)
+	if err != nil {
+		return nil, nil, err
+	}
+	if fxRate == nil && inverse == nil {
+		return nil, nil, fmt.Errorf("no FX rate from %s to %s is in effect on %s", from, to, day)
+	}
+
+	if inverse == nil || (fxRate != nil && fxRate.EffectiveDate >= inverse.EffectiveDate) {
+		rate, err := parseRate(fxRate.Rate)
+		if err != nil {
+			return nil, nil, err
+		}
+		return fxRate, rate, nil
+	}
+	rate, err := parseRate(inverse.Rate)
+	if err != nil {
+		return nil, nil, err
+	}
+	rate.Inv(rate)
+	return &FxRate{From: from, To: to, Rate: rate.FloatString(10), EffectiveDate: inverse.EffectiveDate}, rate, nil
+}
+
+// readLatestFxRate returns the last rate of the pair effective on or before
+// day, or nil if there is none
+func readLatestFxRate(ctx contractapi.TransactionContextInterface, from string, to string, day string) (*FxRate, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(fxRatePrefix, []string{from, to})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var latest *FxRate
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var fxRate FxRate
+		err = json.Unmarshal(queryResponse.Value, &fxRate)
+		if err != nil {
+			return nil, err
+		}
+		if fxRate.EffectiveDate > day {
+			break
+		}
+		latest = &fxRate
+	}
+	return latest, nil
+}
+
+// parseRate checks that rate is a positive decimal number and returns its value
+func parseRate(rate string) (*big.Rat, error) {
+	value, ok := new(big.Rat).SetString(rate)
+	if !ratePattern.MatchString(rate) || !ok || value.Sign() <= 0 {
+		return nil, &ValidationError{Field: "rate", Value: rate, Reason: "not a positive decimal number"}
+	}
+	return value, nil
+}
+
+// parseFxDate returns the UTC day of a YYYY-MM-DD or RFC 3339 date, or of
+// the transaction if date is empty
+func parseFxDate(ctx contractapi.TransactionContextInterface, date string) (string, error) {
+	if _, err := time.Parse(dayLayout, date); err == nil {
+		return date, nil
+	}
+	parsed, err := parseDate(ctx, date)
+	if err != nil {
+		return "", &ValidationError{Field: "date", Value: date, Reason: "not a YYYY-MM-DD or RFC 3339 date"}
+	}
+	return parsed.Format(dayLayout), nil
+}
+
+// roundMinorUnits rounds amount half away from zero to the minor unit of currency
+func roundMinorUnits(amount *big.Rat, currency string) (int64, error) {
+	scaled := new(big.Rat).Mul(amount, new(big.Rat).SetInt(pow10(currencyPrecision[currency])))
+	half := big.NewRat(1, 2)
+	if scaled.Sign() < 0 {
+		half.Neg(half)
+	}
+	scaled.Add(scaled, half)
+	minorUnits := new(big.Int).Quo(scaled.Num(), scaled.Denom())
+	if !minorUnits.IsInt64() {
+		return 0, &ValidationError{Field: "amount", Value: amount.FloatString(currencyPrecision[currency]), Reason: "out of range"}
+	}
+	return minorUnits.Int64(), nil
+}
+
+func pow10(exponent int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exponent)), nil)
+}
@@ -0,0 +1,262 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Key prefix of banks before every entity moved to composite keys
+const legacyBankPrefix = "Bank_"
+
+// legacyUser is the user layout that was stored under the raw user id and
+// embedded the whole transaction history. Before private data, the name and
+// email were stored in the public record as well.
+type legacyUser struct {
+	User
+	Name         string        `json:"name"`
+	Email        string        `json:"email"`
+	Transactions []Transaction `json:"transactions,omitempty"`
+}
+
+// migrationSaltTransientKey is the transient map entry holding the secret
+// the salts of migrated users are derived from
+const migrationSaltTransientKey = "salt"
+
+// MigrationResult counts the legacy records rewritten by MigrateKeys.
+// Balances counts the balances built from embedded histories, and
+// UnbalancedTransactions the history entries left out of them because their
+// amount or currency is invalid; those entries are moved unchanged.
+type MigrationResult struct {
+	Users                  int `json:"users"`
+	Transactions           int `json:"transactions"`
+	TransactionHashes      int `json:"transaction_hashes"`
+	Banks                  int `json:"banks"`
+	UsersPII               int `json:"users_pii"`
+	Balances               int `json:"balances"`
+	UnbalancedTransactions int `json:"unbalanced_transactions"`
+}
+
+// migration is the state of one MigrateKeys run. Fabric does not return the
+// writes of a transaction to its own reads, so the emails reserved and the
+// balances built so far are tracked here.
+type migration struct {
+	secret string
+	result *MigrationResult
+	// seenEmails maps the lowercased emails moved so far to their users
+	seenEmails map[string]string
+	// balances holds the totals of the migrated histories in minor units, in
+	// the order they were first found
+	balances     map[balanceKey]int64
+	balanceOrder []balanceKey
+}
+
+// MigrateKeys rewrites every record stored under a legacy simple key to its
+// composite key. Users stored under their raw id, with any embedded
+// transaction history, hash mappings stored under the raw hash and banks
+// stored under the Bank_ prefix are moved; the legacy keys are deleted. The
+// balances of legacy users are built from their histories, and their names
+// and emails are moved to the private data collection; their salts are
+// derived from a secret of at least 16 characters passed in the transient map
+// under "salt". Running it again on a migrated ledger is a no-op.
+func (s *SmartContract) MigrateKeys(ctx contractapi.TransactionContextInterface) (*MigrationResult, error) {
+	fmt.Println("function MigrateKeys")
+	err := checkAccess(ctx, "MigrateKeys")
+	if err != nil {
+		return nil, err
+	}
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the transient map: %v", err)
+	}
+	m := &migration{
+		secret:     string(transientMap[migrationSaltTransientKey]),
+		result:     &MigrationResult{},
+		seenEmails: map[string]string{},
+		balances:   map[balanceKey]int64{},
+	}
+
+	// An empty range only covers simple keys, so migrated records are never revisited
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		migrated, err := m.migrateKey(ctx, queryResponse.Key, queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+		if !migrated {
+			continue
+		}
+		err = ctx.GetStub().DelState(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete %s from world state: %v", queryResponse.Key, err)
+		}
+	}
+
+	err = m.putBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return m.result, nil
+}
+
+// moveUserPII stores the name and email of a legacy user in the private data
+// collection, salted with a salt derived from the secret
+func (m *migration) moveUserPII(ctx contractapi.TransactionContextInterface, user *legacyUser) error {
+	if len(m.secret) < minSaltLength {
+		return fmt.Errorf("a secret of at least %d characters must be passed in the transient map under %q to move the personal data of user %s",
+			minSaltLength, migrationSaltTransientKey, user.ID)
+	}
+	// Legacy emails were never validated, but must still be unique
+	if user.Email != "" {
+		email := strings.ToLower(user.Email)
+		if other, ok := m.seenEmails[email]; ok && other != user.ID {
+			return fmt.Errorf("the email %s is used by both user %s and user %s", user.Email, other, user.ID)
+		}
+		m.seenEmails[email] = user.ID
+		err := reserveEmail(ctx, user.Email, user.ID)
+		if err != nil {
+			return err
+		}
+	}
+	m.result.UsersPII++
+	return putUserPII(ctx, &user.User, &UserPII{Name: user.Name, Email: user.Email, Salt: hashPII(m.secret, user.ID)})
+}
+
+// migrateKey writes the record found under a legacy key to its composite key
+// and reports whether it recognized the record
+func (m *migration) migrateKey(ctx contractapi.TransactionContextInterface, legacyKey string, value []byte) (bool, error) {
+	if strings.HasPrefix(legacyKey, legacyBankPrefix) {
+		var bank Bank
+		if json.Unmarshal(value, &bank) == nil && legacyBankPrefix+bank.ID == legacyKey {
+			key, err := bankKey(ctx, bank.ID)
+			if err != nil {
+				return false, err
+			}
+			m.result.Banks++
+			return true, putJSON(ctx, key, bank)
+		}
+	}
+
+	var user legacyUser
+	if json.Unmarshal(value, &user) == nil && user.ID != "" && user.ID == legacyKey {
+		for i := range user.Transactions {
+			transaction := user.Transactions[i]
+			transaction.UserId = user.ID
+			minorUnits, valid := normalizeLegacyTransaction(&transaction)
+			err := putTransaction(ctx, &transaction)
+			if err != nil {
+				return false, err
+			}
+			m.result.Transactions++
+			if !valid {
+				m.result.UnbalancedTransactions++
+				continue
+			}
+			err = m.addToBalance(transaction.UserId, transaction.Currency, minorUnits)
+			if err != nil {
+				return false, err
+			}
+		}
+
+		m.result.Users++
+		if user.Name == "" && user.Email == "" {
+			return true, putUser(ctx, &user.User)
+		}
+		return true, m.moveUserPII(ctx, &user)
+	}
+
+	var transactionHashMapUserId TransactionHashMapUserId
+	if json.Unmarshal(value, &transactionHashMapUserId) == nil && transactionHashMapUserId.UserId != "" {
+		key, err := transactionHashKey(ctx, legacyKey)
+		if err != nil {
+			return false, err
+		}
+		m.result.TransactionHashes++
+		return true, putJSON(ctx, key, transactionHashMapUserId)
+	}
+
+	return false, nil
+}
+
+// normalizeLegacyTransaction rewrites the amount, currency and date of an
+// entry of an embedded history in the formats CreateTransaction stores, so
+// the entry can be converted and reversed like any other, and returns its
+// amount in minor units. Legacy entries were never validated: it reports
+// whether the amount and currency are valid, and leaves the fields it cannot
+// parse as they are. YYYY-MM-DD dates are taken as midnight UTC.
+func normalizeLegacyTransaction(transaction *Transaction) (int64, bool) {
+	for _, layout := range []string{time.RFC3339, dayLayout} {
+		if date, err := time.Parse(layout, transaction.Date); err == nil {
+			transaction.Date = date.UTC().Format(time.RFC3339)
+			break
+		}
+	}
+
+	currency, err := parseCurrency(transaction.Currency)
+	if err != nil {
+		return 0, false
+	}
+	minorUnits, err := parseDecimal(transaction.Amount, currency)
+	if err != nil {
+		return 0, false
+	}
+	transaction.Currency = currency
+	transaction.Amount = formatAmount(minorUnits, currency)
+	return minorUnits, true
+}
+
+// addToBalance adds the amount of an entry of an embedded history to the
+// balance of its user
+func (m *migration) addToBalance(userId string, currency string, minorUnits int64) error {
+	key := balanceKey{userId: userId, currency: currency}
+	current, tracked := m.balances[key]
+	if !tracked {
+		m.balanceOrder = append(m.balanceOrder, key)
+	}
+	if (minorUnits > 0 && current > math.MaxInt64-minorUnits) || (minorUnits < 0 && current < math.MinInt64-minorUnits) {
+		return fmt.Errorf("the balance of user %s in %s would overflow", userId, currency)
+	}
+	m.balances[key] = current + minorUnits
+	return nil
+}
+
+// putBalances adds the totals of the migrated histories to the balances of
+// their users. Overdraft protection does not apply, since the history is
+// already on the ledger.
+func (m *migration) putBalances(ctx contractapi.TransactionContextInterface) error {
+	for _, key := range m.balanceOrder {
+		balance, current, err := readBalance(ctx, key.userId, key.currency)
+		if err != nil {
+			return err
+		}
+		minorUnits := m.balances[key]
+		if (minorUnits > 0 && current > math.MaxInt64-minorUnits) || (minorUnits < 0 && current < math.MinInt64-minorUnits) {
+			return fmt.Errorf("the balance of user %s in %s would overflow", key.userId, key.currency)
+		}
+		balance.Balance = formatAmount(current+minorUnits, key.currency)
+		balanceKey, err := createKey(ctx, balancePrefix, key.userId, key.currency)
+		if err != nil {
+			return err
+		}
+		err = putJSON(ctx, balanceKey, balance)
+		if err != nil {
+			return err
+		}
+		m.result.Balances++
+	}
+	return nil
+}
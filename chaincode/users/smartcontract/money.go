@@ -0,0 +1,134 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ValidationError reports a transaction field that was rejected. Its message
+// is the JSON encoding of the error, so clients can decode which field failed.
+type ValidationError struct {
+	Field  string `json:"field"`
+	Value  string `json:"value"`
+	Reason string `json:"reason"`
+}
+
+func (e *ValidationError) Error() string {
+	errorJson, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Value, e.Reason)
+	}
+	return string(errorJson)
+}
+
+// currencyPrecision maps the active ISO 4217 currency codes to the number of
+// digits of their minor unit
+var currencyPrecision = map[string]int{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2,
+	"AWG": 2, "AZN": 2, "BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BHD": 3, "BIF": 0,
+	"BMD": 2, "BND": 2, "BOB": 2, "BRL": 2, "BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2,
+	"BZD": 2, "CAD": 2, "CDF": 2, "CHF": 2, "CLP": 0, "CNY": 2, "COP": 2, "CRC": 2,
+	"CUP": 2, "CVE": 2, "CZK": 2, "DJF": 0, "DKK": 2, "DOP": 2, "DZD": 2, "EGP": 2,
+	"ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2, "GHS": 2,
+	"GIP": 2, "GMD": 2, "GNF": 0, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2,
+	"HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "IQD": 3, "IRR": 2, "ISK": 0, "JMD": 2,
+	"JOD": 3, "JPY": 0, "KES": 2, "KGS": 2, "KHR": 2, "KMF": 0, "KPW": 2, "KRW": 0,
+	"KWD": 3, "KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2, "LSL": 2,
+	"LYD": 3, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2, "MOP": 2,
+	"MRU": 2, "MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MYR": 2, "MZN": 2, "NAD": 2,
+	"NGN": 2, "NIO": 2, "NOK": 2, "NPR": 2, "NZD": 2, "OMR": 3, "PAB": 2, "PEN": 2,
+	"PGK": 2, "PHP": 2, "PKR": 2, "PLN": 2, "PYG": 0, "QAR": 2, "RON": 2, "RSD": 2,
+	"RUB": 2, "RWF": 0, "SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2, "SGD": 2,
+	"SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SVC": 2, "SYP": 2,
+	"SZL": 2, "THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2, "TRY": 2, "TTD": 2,
+	"TWD": 2, "TZS": 2, "UAH": 2, "UGX": 0, "USD": 2, "UYU": 2, "UZS": 2, "VES": 2,
+	"VND": 0, "VUV": 0, "WST": 2, "XAF": 0, "XCD": 2, "XOF": 0, "XPF": 0, "YER": 2,
+	"ZAR": 2, "ZMW": 2, "ZWL": 2,
+}
+
+var amountPattern = regexp.MustCompile(`^(-?)([0-9]+)(?:\.([0-9]+))?$`)
+
+// parseCurrency returns the upper-cased ISO 4217 code of currency
+func parseCurrency(currency string) (string, error) {
+	code := strings.ToUpper(currency)
+	if _, ok := currencyPrecision[code]; !ok {
+		return "", &ValidationError{Field: "currency", Value: currency, Reason: "not an ISO 4217 currency code"}
+	}
+	return code, nil
+}
+
+// parseAmount parses a decimal transaction amount of currency into its minor
+// units. Negative amounts are debits; zero amounts are rejected.
+func parseAmount(amount string, currency string) (int64, error) {
+	minor, err := parseDecimal(amount, currency)
+	if err != nil {
+		return 0, err
+	}
+	if minor == 0 {
+		return 0, &ValidationError{Field: "amount", Value: amount, Reason: "must not be zero"}
+	}
+	return minor, nil
+}
+
+// parseDecimal parses a decimal amount of currency into its minor units
+func parseDecimal(amount string, currency string) (int64, error) {
+	precision := currencyPrecision[currency]
+	matches := amountPattern.FindStringSubmatch(amount)
+	if matches == nil {
+		return 0, &ValidationError{Field: "amount", Value: amount, Reason: "not a decimal number"}
+	}
+	sign, units, fraction := matches[1], matches[2], matches[3]
+	if len(fraction) > precision {
+		return 0, &ValidationError{Field: "amount", Value: amount, Reason: fmt.Sprintf("%s allows at most %d decimal places", currency, precision)}
+	}
+
+	digits := strings.TrimLeft(units+fraction+strings.Repeat("0", precision-len(fraction)), "0")
+	var minor int64
+	for _, digit := range digits {
+		if minor > (math.MaxInt64-int64(digit-'0'))/10 {
+			return 0, &ValidationError{Field: "amount", Value: amount, Reason: "out of range"}
+		}
+		minor = minor*10 + int64(digit-'0')
+	}
+	if sign == "-" {
+		minor = -minor
+	}
+	return minor, nil
+}
+
+// formatAmount formats minor units of currency as a decimal amount with the
+// currency's precision, e.g. 20000 USD as "200.00"
+func formatAmount(minor int64, currency string) string {
+	precision := currencyPrecision[currency]
+	sign := ""
+	magnitude := uint64(minor)
+	if minor < 0 {
+		sign = "-"
+		magnitude = uint64(-minor)
+	}
+	digits := fmt.Sprintf("%0*d", precision+1, magnitude)
+	if precision == 0 {
+		return sign + digits
+	}
+	return sign + digits[:len(digits)-precision] + "." + digits[len(digits)-precision:]
+}
+
+// parseDate parses an RFC 3339 date into UTC. An empty date defaults to the
+// timestamp of the transaction proposal.
+func parseDate(ctx contractapi.TransactionContextInterface, date string) (time.Time, error) {
+	if date == "" {
+		return txTime(ctx)
+	}
+
+	parsed, err := time.Parse(time.RFC3339, date)
+	if err != nil {
+		return time.Time{}, &ValidationError{Field: "date", Value: date, Reason: "not an RFC 3339 date"}
+	}
+	return parsed.UTC(), nil
+}
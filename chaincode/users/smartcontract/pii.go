@@ -0,0 +1,222 @@
+package smartcontract
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// UserPII holds the personal data of a user. It is kept in the private data
+// collection and only reaches the chaincode through the transient map; the
+// public user record holds salted hashes of Name and Email.
+type UserPII struct {
+	DocType string `json:"docType,omitempty" metadata:",optional"`
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Email   string `json:"email"`
+	Salt    string `json:"salt"`
+}
+
+// PIIInput is the transient "user" entry passed to CreateUser, UpdateUser and
+// VerifyUserPII. The salt is chosen by the client, because chaincode must be
+// deterministic across endorsers and cannot generate one.
+type PIIInput struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Salt  string `json:"salt"`
+}
+
+// piiCollection is the private data collection defined in collections_config.json
+const piiCollection = "userPIICollection"
+
+// piiTransientKey is the transient map entry holding a PIIInput
+const piiTransientKey = "user"
+
+const userPIIDocType = "userPII"
+
+// minSaltLength keeps the hashes on the public ledger from being reversed by
+// hashing likely names and emails
+const minSaltLength = 16
+
+// GetUserPII returns the personal data of an active user. Only clients of
+// organizations that are members of the collection can read it.
+func (s *SmartContract) GetUserPII(ctx contractapi.TransactionContextInterface, id string) (*UserPII, error) {
+	fmt.Println("function GetUserPII")
+	_, err := s.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return readUserPII(ctx, id)
+}
+
+// GetUsersByName returns the personal data of the active users with the given
+// name. It runs a rich query on the private data collection, so it needs
+// CouchDB and cannot be paginated.
+func (s *SmartContract) GetUsersByName(ctx contractapi.TransactionContextInterface, name string) ([]*UserPII, error) {
+	fmt.Println("function GetUsersByName")
+	query, err := richQuery(userPIIDocType, map[string]interface{}{"name": name})
+	if err != nil {
+		return nil, err
+	}
+	resultsIterator, err := ctx.GetStub().GetPrivateDataQueryResult(piiCollection, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query the private data: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	users := []*UserPII{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var pii UserPII
+		err = json.Unmarshal(queryResponse.Value, &pii)
+		if err != nil {
+			return nil, err
+		}
+		user, err := readUser(ctx, pii.ID)
+		if err != nil {
+			return nil, err
+		}
+		if user.Deactivated {
+			continue
+		}
+		users = append(users, &pii)
+	}
+
+	return users, nil
+}
+
+// VerifyUserPII reports whether the name and email presented in the transient
+// map, with the salt the user shared, match the hashes on the public ledger.
+// Fields left empty are not checked, so a name or an email can be verified
+// alone. It does not read the private data collection, so any organization
+// can verify.
+func (s *SmartContract) VerifyUserPII(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	fmt.Println("function VerifyUserPII")
+	input, err := readTransientPII(ctx)
+	if err != nil {
+		return false, err
+	}
+	if input.Name == "" && input.Email == "" {
+		return false, &ValidationError{Field: "user", Value: "", Reason: "a name or an email must be presented"}
+	}
+	user, err := readUser(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	if input.Name != "" && hashPII(input.Salt, input.Name) != user.NameHash {
+		return false, nil
+	}
+	if input.Email != "" && hashPII(input.Salt, input.Email) != user.EmailHash {
+		return false, nil
+	}
+	return true, nil
+}
+
+// readTransientPII decodes the PIIInput passed in the transient map
+func readTransientPII(ctx contractapi.TransactionContextInterface) (*PIIInput, error) {
+	transientMap, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the transient map: %v", err)
+	}
+	inputJson, ok := transientMap[piiTransientKey]
+	if !ok {
+		return nil, fmt.Errorf("the %s entry must be passed in the transient map", piiTransientKey)
+	}
+
+	var input PIIInput
+	err = json.Unmarshal(inputJson, &input)
+	if err != nil {
+		// The entry holds personal data, so it is left out of the error
+		return nil, &ValidationError{Field: piiTransientKey, Value: "", Reason: "not a JSON object with name, email and salt"}
+	}
+	err = validateSalt(input.Salt)
+	if err != nil {
+		return nil, err
+	}
+	return &input, nil
+}
+
+// validateSalt checks that salt is long enough to protect the hashes
+func validateSalt(salt string) error {
+	if len(salt) < minSaltLength {
+		return &ValidationError{Field: "salt", Value: "", Reason: fmt.Sprintf("must be at least %d characters", minSaltLength)}
+	}
+	return nil
+}
+
+// hashPII returns the hex encoded SHA-256 hash of the salted value
+func hashPII(salt string, value string) string {
+	hash := sha256.Sum256([]byte(salt + value))
+	return hex.EncodeToString(hash[:])
+}
+
+// readUserPII returns the personal data of the user from the private data collection
+func readUserPII(ctx contractapi.TransactionContextInterface, id string) (*UserPII, error) {
+	key, err := userKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	piiJson, err := ctx.GetStub().GetPrivateData(piiCollection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from private data: %v", err)
+	}
+	if piiJson == nil {
+		return nil, fmt.Errorf("the personal data of user %s is not available", id)
+	}
+
+	var pii UserPII
+	err = json.Unmarshal(piiJson, &pii)
+	if err != nil {
+		return nil, err
+	}
+	return &pii, nil
+}
+
+// putUserPII stores the personal data of the user in the private data
+// collection and its salted hashes on the public user record
+func putUserPII(ctx contractapi.TransactionContextInterface, user *User, pii *UserPII) error {
+	key, err := userKey(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+	pii.DocType = userPIIDocType
+	pii.ID = user.ID
+	err = putPrivateJSON(ctx, key, pii)
+	if err != nil {
+		return err
+	}
+
+	user.NameHash = hashPII(pii.Salt, pii.Name)
+	user.EmailHash = hashPII(pii.Salt, pii.Email)
+	return putUser(ctx, user)
+}
+
+// putPrivateJSON stores the JSON encoding of value under key in the private data collection
+func putPrivateJSON(ctx contractapi.TransactionContextInterface, key string, value interface{}) error {
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutPrivateData(piiCollection, key, valueJson)
+	if err != nil {
+		return fmt.Errorf("failed to put to private data: %v", err)
+	}
+	return nil
+}
+
+// delPrivateKey deletes key from the private data collection
+func delPrivateKey(ctx contractapi.TransactionContextInterface, key string) error {
+	err := ctx.GetStub().DelPrivateData(piiCollection, key)
+	if err != nil {
+		return fmt.Errorf("failed to delete from private data: %v", err)
+	}
+	return nil
+}
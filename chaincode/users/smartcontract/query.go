@@ -0,0 +1,164 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Document types stored in the docType field, so that CouchDB selectors and
+// the indexes under META-INF/statedb/couchdb only match one kind of record
+const userDocType = "user"
+const transactionDocType = "transaction"
+
+// deactivatedField is the user field a selector has to name to return
+// deactivated users
+const deactivatedField = "deactivated"
+
+// QueryUsers returns one page of the public user records matching a CouchDB
+// selector, e.g. {"deactivated_at": {"$gte": "2022-04-01"}}. Names and emails
+// are not part of the public records, see GetUsersByName. Deactivated users
+// are skipped unless the selector matches on the deactivated field, at its
+// top level or within combination operators such as $and, so a page may hold
+// fewer records than were fetched. Rich queries need CouchDB as the state
+// database.
+func (s *SmartContract) QueryUsers(ctx contractapi.TransactionContextInterface, selectorJSON string, pageSize int32, bookmark string) (*UserPage, error) {
+	fmt.Println("function QueryUsers")
+	selector, err := parseSelector(selectorJSON)
+	if err != nil {
+		return nil, err
+	}
+	includeDeactivated := namesField(selector, deactivatedField)
+
+	resultsIterator, metadata, err := queryWithPagination(ctx, userDocType, selector, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	users := []*User{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var user User
+		err = json.Unmarshal(queryResponse.Value, &user)
+		if err != nil {
+			return nil, err
+		}
+		if user.Deactivated && !includeDeactivated {
+			continue
+		}
+		users = append(users, &user)
+	}
+
+	return &UserPage{
+		Records:             users,
+		Bookmark:            metadata.Bookmark,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+// QueryTransactions returns one page of the transactions matching a CouchDB
+// selector, e.g. {"currency": "USD", "date": {"$gte": "2022-04-01T00:00:00Z"}}
+func (s *SmartContract) QueryTransactions(ctx contractapi.TransactionContextInterface, selectorJSON string, pageSize int32, bookmark string) (*TransactionPage, error) {
+	fmt.Println("function QueryTransactions")
+	selector, err := parseSelector(selectorJSON)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := queryWithPagination(ctx, transactionDocType, selector, pageSize, bookmark)
+	if err != nil {
+		return nil, err
+	}
+	defer resultsIterator.Close()
+
+	transactions := []*Transaction{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var transaction Transaction
+		err = json.Unmarshal(queryResponse.Value, &transaction)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, &transaction)
+	}
+
+	return &TransactionPage{
+		Records:             transactions,
+		Bookmark:            metadata.Bookmark,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+// parseSelector decodes a CouchDB selector, which must be a JSON object
+func parseSelector(selectorJSON string) (map[string]interface{}, error) {
+	selector := map[string]interface{}{}
+	if selectorJSON == "" {
+		return selector, nil
+	}
+	err := json.Unmarshal([]byte(selectorJSON), &selector)
+	if err != nil || selector == nil {
+		return nil, &ValidationError{Field: "selector", Value: selectorJSON, Reason: "not a JSON object"}
+	}
+	return selector, nil
+}
+
+// namesField reports whether a selector matches on field, at its top level or
+// within the operands of combination operators such as $and, $or and $not
+func namesField(selector interface{}, field string) bool {
+	switch value := selector.(type) {
+	case map[string]interface{}:
+		for key, operand := range value {
+			if key == field {
+				return true
+			}
+			if strings.HasPrefix(key, "$") && namesField(operand, field) {
+				return true
+			}
+		}
+	case []interface{}:
+		for _, operand := range value {
+			if namesField(operand, field) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// richQuery returns the CouchDB query for the records of docType matching selector
+func richQuery(docType string, selector map[string]interface{}) (string, error) {
+	selector["docType"] = docType
+	queryJson, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return "", fmt.Errorf("failed to obtain JSON encoding: %v", err)
+	}
+	return string(queryJson), nil
+}
+
+// queryWithPagination runs a rich query for one page of the records of
+// docType matching selector
+func queryWithPagination(ctx contractapi.TransactionContextInterface, docType string, selector map[string]interface{},
+	pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	query, err := richQuery(docType, selector)
+	if err != nil {
+		return nil, nil, err
+	}
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(query, pageSize, bookmark)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to query the world state: %v", err)
+	}
+	return resultsIterator, metadata, nil
+}
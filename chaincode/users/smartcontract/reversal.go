@@ -0,0 +1,129 @@
+package smartcontract
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// reversalSuffix is appended to the hash of a transaction to form the hash of
+// the compensating entry that reverses it
+const reversalSuffix = ":reversal"
+
+// ReverseTransaction corrects a recorded transaction by appending a
+// compensating entry of the opposite amount, hashed <hash>:reversal. The
+// original is kept and marked as reversed, so it cannot be reversed twice,
+// and its bank counts the reversal; legacy entries recorded without a bank
+// are reversed without one. Transfer entries and compensating entries cannot
+// be reversed.
+func (s *SmartContract) ReverseTransaction(ctx contractapi.TransactionContextInterface, hash string, reason string) (*Transaction, error) {
+	fmt.Println("function ReverseTransaction")
+	err := checkAccess(ctx, "ReverseTransaction")
+	if err != nil {
+		return nil, err
+	}
+	if reason == "" {
+		return nil, &ValidationError{Field: "reason", Value: reason, Reason: "must not be empty"}
+	}
+	original, err := readTransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if original == nil {
+		return nil, fmt.Errorf("the transaction %s does not exist", hash)
+	}
+	if original.ReversedBy != "" {
+		return nil, fmt.Errorf("the transaction %s is already reversed by %s", hash, original.ReversedBy)
+	}
+	if original.ReversalOf != "" {
+		return nil, fmt.Errorf("the transaction %s reverses %s and cannot be reversed", hash, original.ReversalOf)
+	}
+	if original.TransferId != "" {
+		return nil, fmt.Errorf("the transaction %s belongs to transfer %s and cannot be reversed on its own", hash, original.TransferId)
+	}
+	existing, err := readTransactionByHash(ctx, hash+reversalSuffix)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("the transaction %s already exists", hash+reversalSuffix)
+	}
+	// Legacy entries MigrateKeys could not normalize are kept as they were
+	if _, ok := currencyPrecision[original.Currency]; !ok {
+		return nil, fmt.Errorf("the transaction %s has the invalid currency %q and cannot be reversed", hash, original.Currency)
+	}
+	minorUnits, err := parseAmount(original.Amount, original.Currency)
+	if err != nil {
+		return nil, fmt.Errorf("the transaction %s has an invalid amount and cannot be reversed: %v", hash, err)
+	}
+	// Legacy entries were recorded without a bank
+	var bank *Bank
+	if original.BankId != "" {
+		bank, err = s.GetBankByID(ctx, original.BankId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	date, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	balance, err := updateBalance(ctx, original.UserId, original.Currency, -minorUnits)
+	if err != nil {
+		return nil, err
+	}
+	reversal := Transaction{
+		UserId:     original.UserId,
+		Hash:       hash + reversalSuffix,
+		Amount:     formatAmount(-minorUnits, original.Currency),
+		Currency:   original.Currency,
+		Date:       date.Format(time.RFC3339),
+		BankId:     original.BankId,
+		ReversalOf: hash,
+		Reason:     reason,
+	}
+	err = putTransaction(ctx, &reversal)
+	if err != nil {
+		return nil, err
+	}
+	hashKey, err := transactionHashKey(ctx, reversal.Hash)
+	if err != nil {
+		return nil, err
+	}
+	err = putJSON(ctx, hashKey, TransactionHashMapUserId{UserId: reversal.UserId})
+	if err != nil {
+		return nil, err
+	}
+
+	original.ReversedBy = reversal.Hash
+	err = putTransaction(ctx, original)
+	if err != nil {
+		return nil, err
+	}
+
+	events := []EntityEvent{
+		{Type: TransactionReversed, EntityID: hash, Data: original},
+		{Type: TransactionCreated, EntityID: reversal.Hash, Data: reversal},
+		{Type: BalanceUpdated, EntityID: reversal.UserId + "/" + reversal.Currency, Data: balance},
+	}
+	if bank != nil {
+		bank.ReversalCount++
+		err = putBank(ctx, bank)
+		if err != nil {
+			return nil, err
+		}
+		err = recordBankStats(ctx, &reversal)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, EntityEvent{Type: BankUpdated, EntityID: bank.ID, Data: bank})
+	}
+
+	err = emitEvents(ctx, "ReverseTransaction", events...)
+	if err != nil {
+		return nil, err
+	}
+	return &reversal, nil
+}
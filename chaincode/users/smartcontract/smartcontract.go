@@ -3,6 +3,8 @@ package smartcontract
 import (
 	"fmt"
 	"encoding/json"
+	"strings"
+	"time"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -11,69 +13,172 @@ type SmartContract struct {
 	contractapi.Contract
 }
 
-// User Data struct
+// User Data struct. The name and email of the user are kept in the private
+// data collection as UserPII; the public record holds their salted hashes.
 type User struct {
+	DocType string `json:"docType,omitempty" metadata:",optional"`
 	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
-	Transactions []Transaction `json:"transactions,omitempty" metadata:",optional"`
+	NameHash  string `json:"name_hash"`
+	EmailHash string `json:"email_hash"`
+	Deactivated        bool   `json:"deactivated"`
+	DeactivatedAt      string `json:"deactivated_at,omitempty" metadata:",optional"`
+	DeactivationReason string `json:"deactivation_reason,omitempty" metadata:",optional"`
 }
 
+// Transaction is one entry of a user's history. Amount is a decimal with the
+// precision of its ISO 4217 Currency, negative for debits, and Date is RFC 3339.
+// Entries written by TransferBetweenUsers carry the ID of their transfer, and
+// reversed entries the hash of the compensating entry that reverses them.
 type Transaction struct {
+	DocType      string `json:"docType,omitempty" metadata:",optional"`
+	UserId       string `json:"user_id"`
 	Hash         string `json:"hash"`
 	Amount       string `json:"amount"`
 	Currency string `json:"currency"`
 	Date    string `json:"date"`
+	BankId       string `json:"bank_id"`
+	TransferId   string `json:"transfer_id,omitempty" metadata:",optional"`
+	ReversalOf   string `json:"reversal_of,omitempty" metadata:",optional"`
+	ReversedBy   string `json:"reversed_by,omitempty" metadata:",optional"`
+	Reason       string `json:"reason,omitempty" metadata:",optional"`
+}
+
+// PurgedUser is the tombstone PurgeUser leaves, so that the id of a purged
+// user, whose transactions and balances stay on the ledger, is never reused
+type PurgedUser struct {
+	ID       string `json:"id"`
+	PurgedAt string `json:"purged_at"`
+}
+
+// PurgedTransactionHash is the tombstone PurgeUser leaves in place of the
+// hash mapping of each transaction of the purged user, so that the hash of a
+// transaction still on the ledger is never recorded again
+type PurgedTransactionHash struct {
+	Hash     string `json:"hash"`
+	UserId   string `json:"user_id"`
+	PurgedAt string `json:"purged_at"`
 }
 
 type TransactionHashMapUserId struct {
 	UserId	string `json:"user_id"`
 }
 
+// Bank processes transactions. SwiftCode is its SWIFT/BIC code, Country its
+// ISO 3166-1 alpha-2 country code, and Status is active or inactive.
+// ReversalCount counts the transactions of TransactionCount reversed since.
 type Bank struct {
 	ID	string `json:"id"`
 	Name	string `json:"name"`
 	TransactionCount	int `json:"transaction_count"`
+	ReversalCount	int `json:"reversal_count"`
+	SwiftCode	string `json:"swift_code"`
+	Country	string `json:"country"`
+	Status	string `json:"status"`
 }
 
-const bankPrefix = "Bank_"
+// TransactionResult tells whether CreateTransaction recorded the transaction
+// or found it already recorded
+type TransactionResult struct {
+	Status      string       `json:"status"`
+	Transaction *Transaction `json:"transaction"`
+}
 
-func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface) error {
-	var cathayBank Bank = Bank{
-		ID: "04231910",
-		Name: "國泰世華商業銀行",
-		TransactionCount: 0,
-	}
-	var fubonBank Bank = Bank{
-			ID: "03750168",
-			Name: "台北富邦商業銀行",
-			TransactionCount: 0,
-	}
-	cathayBankJson, err := json.Marshal(cathayBank)
+// Statuses of a TransactionResult
+const transactionCreated = "created"
+const transactionDuplicate = "duplicate"
+
+// UserHistoryEntry is one version of a user record. User is nil for deletions.
+type UserHistoryEntry struct {
+	TxID      string `json:"tx_id"`
+	Timestamp string `json:"timestamp"`
+	IsDelete  bool   `json:"is_delete"`
+	User      *User  `json:"user,omitempty" metadata:",optional"`
+}
+
+// UserPage is one page of users
+type UserPage struct {
+	Records             []*User `json:"records"`
+	Bookmark            string  `json:"bookmark"`
+	FetchedRecordsCount int32   `json:"fetched_records_count"`
+}
+
+// TransactionPage is one page of transactions
+type TransactionPage struct {
+	Records             []*Transaction `json:"records"`
+	Bookmark            string         `json:"bookmark"`
+	FetchedRecordsCount int32          `json:"fetched_records_count"`
+}
+
+// Define objectType names for prefix
+const userPrefix = "user"
+const purgedUserPrefix = "purgedUser"
+const purgedTransactionHashPrefix = "purgedTxhash"
+const transactionPrefix = "txn"
+const transactionHashPrefix = "txhash"
+const bankPrefix = "bank"
+const bankTransactionPrefix = "bankTxn"
+
+// InitLedger seeds the banks listed in banksJSON, a JSON array of banks with
+// id, name, swift_code and country, e.g.
+// [{"id": "04231910", "name": "國泰世華商業銀行", "swift_code": "UWCBTWTP", "country": "TW"}]
+func (s *SmartContract) InitLedger(ctx contractapi.TransactionContextInterface, banksJSON string) error {
+	err := bootstrapAccess(ctx)
 	if err != nil {
 		return err
 	}
-	fubonBankJson, err := json.Marshal(fubonBank)
+	var banks []Bank
+	err = json.Unmarshal([]byte(banksJSON), &banks)
 	if err != nil {
-		return err
+		return &ValidationError{Field: "banks", Value: banksJSON, Reason: "not a JSON array of banks"}
 	}
-	ctx.GetStub().PutState(bankPrefix+"04231910", cathayBankJson)
-	ctx.GetStub().PutState(bankPrefix+"03750168", fubonBankJson)
-	return nil
+
+	events := []EntityEvent{}
+	seeded := map[string]bool{}
+	for _, seed := range banks {
+		if seeded[seed.ID] {
+			return fmt.Errorf("the bank %s is listed twice", seed.ID)
+		}
+		seeded[seed.ID] = true
+		bank, err := newBank(ctx, seed.ID, seed.Name, seed.SwiftCode, seed.Country)
+		if err != nil {
+			return err
+		}
+		events = append(events, EntityEvent{Type: BankCreated, EntityID: bank.ID, Data: bank})
+	}
+	for _, event := range events {
+		err = putBank(ctx, event.Data.(*Bank))
+		if err != nil {
+			return err
+		}
+	}
+	if len(events) == 0 {
+		return nil
+	}
+	return emitEvents(ctx, "InitLedger", events...)
 }
 
 func (s *SmartContract) UserExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
 	fmt.Println("function UserExists")
 	//TODO UserExists
-	assetJSON, err := ctx.GetStub().GetState(id)
+	key, err := userKey(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	assetJSON, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return false, fmt.Errorf("failed to read from world state: %v", err)
 	}
 	return assetJSON != nil, nil
 }
 
-func (s *SmartContract) CreateUser(ctx contractapi.TransactionContextInterface, id string, name string, email string) error {
+// CreateUser creates a user from the name, email and salt passed in the
+// transient map under "user"
+func (s *SmartContract) CreateUser(ctx contractapi.TransactionContextInterface, id string) error {
 	fmt.Println("function CreateUser")
+	err := checkAccess(ctx, "CreateUser")
+	if err != nil {
+		return err
+	}
 	//TODO CreateUser
 	exists, err := s.UserExists(ctx, id)
 	if err != nil {
@@ -82,72 +187,293 @@ func (s *SmartContract) CreateUser(ctx contractapi.TransactionContextInterface,
 	if exists {
 		return fmt.Errorf("the user %s already exists", id)
 	}
+	err = checkNotPurged(ctx, id)
+	if err != nil {
+		return err
+	}
+	input, err := readTransientPII(ctx)
+	if err != nil {
+		return err
+	}
+	err = parseEmail(input.Email)
+	if err != nil {
+		return err
+	}
+	err = reserveEmail(ctx, input.Email, id)
+	if err != nil {
+		return err
+	}
 
 	user := User{
 		ID: id,
-		Name: name,
-		Email: email,
 	}
-	userJson, err := json.Marshal(user)
+	err = putUserPII(ctx, &user, &UserPII{Name: input.Name, Email: input.Email, Salt: input.Salt})
 	if err != nil {
 		return err
 	}
-	ctx.GetStub().PutState(id, userJson)
-	return nil
+	return emitEvents(ctx, "CreateUser", EntityEvent{Type: UserCreated, EntityID: id, Data: user})
 }
 
+// GetUser returns an active user; deactivated users are reported as such
 func (s *SmartContract) GetUser(ctx contractapi.TransactionContextInterface, id string) (*User, error) {
 	fmt.Println("function GetUser")
 	//TODO GetUser
-	userJson, err := ctx.GetStub().GetState(id)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read from world state: %v", err)
-	}
-	if userJson == nil {
-		return nil, fmt.Errorf("the user %s does not exist", id)
-	}
-
-	var user User
-	err = json.Unmarshal(userJson, &user)
+	user, err := readUser(ctx, id)
 	if err != nil {
 		return nil, err
 	}
-	return &user, nil
+	if user.Deactivated {
+		return nil, fmt.Errorf("the user %s is deactivated", id)
+	}
+	return user, nil
 }
 
-func (s *SmartContract) UpdateUser(ctx contractapi.TransactionContextInterface, id string, name string, email string) error {
+// UpdateUser replaces the name and email of a user with the ones passed in the
+// transient map under "user", hashed with the new salt
+func (s *SmartContract) UpdateUser(ctx contractapi.TransactionContextInterface, id string) error {
 	fmt.Println("function UpdateUser")
+	err := checkAccess(ctx, "UpdateUser")
+	if err != nil {
+		return err
+	}
 	//TODO UpdateUser
 	user, err := s.GetUser(ctx, id)
 	if err != nil {
 		return err
 	}
-	user.Email = email
-	user.Name = name
-	userJson, err := json.Marshal(user)
-
-	return ctx.GetStub().PutState(id, userJson)
+	pii, err := readUserPII(ctx, id)
+	if err != nil {
+		return err
+	}
+	input, err := readTransientPII(ctx)
+	if err != nil {
+		return err
+	}
+	err = parseEmail(input.Email)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(pii.Email, input.Email) {
+		err = reserveEmail(ctx, input.Email, id)
+		if err != nil {
+			return err
+		}
+		err = releaseEmail(ctx, pii.Email, id)
+		if err != nil {
+			return err
+		}
+	}
+	pii.Email = input.Email
+	pii.Name = input.Name
+	pii.Salt = input.Salt
+	err = putUserPII(ctx, user, pii)
+	if err != nil {
+		return err
+	}
+	return emitEvents(ctx, "UpdateUser", EntityEvent{Type: UserUpdated, EntityID: id, Data: user})
 }
 
-func (s *SmartContract) DeleteUser(ctx contractapi.TransactionContextInterface, id string) error {
+// DeleteUser deactivates the user, keeping its record and transactions on the
+// ledger. Its email stays reserved so that the user can be restored.
+func (s *SmartContract) DeleteUser(ctx contractapi.TransactionContextInterface, id string, reason string) error {
 	fmt.Println("function DeleteUser")
+	err := checkAccess(ctx, "DeleteUser")
+	if err != nil {
+		return err
+	}
 	//TODO DeleteUser
-	exists, err := s.UserExists(ctx, id)
+	user, err := s.GetUser(ctx, id)
 	if err != nil {
 		return err
 	}
-	if !exists {
-		return fmt.Errorf("the user %s does not exist", id)
+	deactivatedAt, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	user.Deactivated = true
+	user.DeactivatedAt = deactivatedAt.Format(time.RFC3339)
+	user.DeactivationReason = reason
+	err = putUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	return emitEvents(ctx, "DeleteUser", EntityEvent{Type: UserDeactivated, EntityID: id, Data: user})
+}
+
+// RestoreUser reactivates a user deactivated by DeleteUser
+func (s *SmartContract) RestoreUser(ctx contractapi.TransactionContextInterface, id string) error {
+	fmt.Println("function RestoreUser")
+	err := checkAccess(ctx, "RestoreUser")
+	if err != nil {
+		return err
+	}
+	user, err := readUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !user.Deactivated {
+		return fmt.Errorf("the user %s is not deactivated", id)
+	}
+
+	user.Deactivated = false
+	user.DeactivatedAt = ""
+	user.DeactivationReason = ""
+	err = putUser(ctx, user)
+	if err != nil {
+		return err
+	}
+	return emitEvents(ctx, "RestoreUser", EntityEvent{Type: UserRestored, EntityID: id, Data: user})
+}
+
+// PurgeUser deletes the user record, its personal data, its email reservation
+// and the hash mappings of its transactions. The transactions themselves stay on the
+// ledger so that the records of the banks that processed them are complete, and
+// so do the balances they add up to; tombstones keep the id from being reused
+// by a new user, who would otherwise inherit them, and the hashes from being
+// recorded again.
+func (s *SmartContract) PurgeUser(ctx contractapi.TransactionContextInterface, id string) error {
+	fmt.Println("function PurgeUser")
+	err := checkAccess(ctx, "PurgeUser")
+	if err != nil {
+		return err
+	}
+	_, err = readUser(ctx, id)
+	if err != nil {
+		return err
+	}
+	pii, err := readUserPII(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(transactionPrefix, []string{id})
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
 	}
+	defer resultsIterator.Close()
+
+	date, err := txTime(ctx)
+	if err != nil {
+		return err
+	}
+	events := []EntityEvent{{Type: UserDeleted, EntityID: id}}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return fmt.Errorf("failed to split the composite key %s: %v", queryResponse.Key, err)
+		}
+		hash := attributes[1]
 
-	return ctx.GetStub().DelState(id)
+		hashKey, err := transactionHashKey(ctx, hash)
+		if err != nil {
+			return err
+		}
+		transactionHashMapJson, err := ctx.GetStub().GetState(hashKey)
+		if err != nil {
+			return fmt.Errorf("failed to read from world state: %v", err)
+		}
+		var transactionHashMapUserId TransactionHashMapUserId
+		if transactionHashMapJson == nil || json.Unmarshal(transactionHashMapJson, &transactionHashMapUserId) != nil || transactionHashMapUserId.UserId != id {
+			continue
+		}
+		err = ctx.GetStub().DelState(hashKey)
+		if err != nil {
+			return fmt.Errorf("failed to delete from world state: %v", err)
+		}
+		tombstoneKey, err := createKey(ctx, purgedTransactionHashPrefix, hash)
+		if err != nil {
+			return err
+		}
+		err = putJSON(ctx, tombstoneKey, PurgedTransactionHash{Hash: hash, UserId: id, PurgedAt: date.Format(time.RFC3339)})
+		if err != nil {
+			return err
+		}
+		events = append(events, EntityEvent{Type: TransactionHashDeleted, EntityID: hash})
+	}
+
+	err = releaseEmail(ctx, pii.Email, id)
+	if err != nil {
+		return err
+	}
+	key, err := userKey(ctx, id)
+	if err != nil {
+		return err
+	}
+	err = delPrivateKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().DelState(key)
+	if err != nil {
+		return fmt.Errorf("failed to delete from world state: %v", err)
+	}
+	tombstoneKey, err := createKey(ctx, purgedUserPrefix, id)
+	if err != nil {
+		return err
+	}
+	err = putJSON(ctx, tombstoneKey, PurgedUser{ID: id, PurgedAt: date.Format(time.RFC3339)})
+	if err != nil {
+		return err
+	}
+	return emitEvents(ctx, "PurgeUser", events...)
 }
 
-func (s *SmartContract) GetAllUsers(ctx contractapi.TransactionContextInterface) ([]*User, error) {
+// GetUserHistory returns every version of the user record, newest first,
+// including deletions
+func (s *SmartContract) GetUserHistory(ctx contractapi.TransactionContextInterface, id string) ([]*UserHistoryEntry, error) {
+	fmt.Println("function GetUserHistory")
+	key, err := userKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history of user %s: %v", id, err)
+	}
+	defer resultsIterator.Close()
+
+	history := []*UserHistoryEntry{}
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := UserHistoryEntry{
+			TxID:     modification.TxId,
+			IsDelete: modification.IsDelete,
+		}
+		if modification.Timestamp != nil {
+			entry.Timestamp = time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos)).UTC().Format(time.RFC3339Nano)
+		}
+		if !modification.IsDelete {
+			var user User
+			err = json.Unmarshal(modification.Value, &user)
+			if err != nil {
+				return nil, err
+			}
+			entry.User = &user
+		}
+		history = append(history, &entry)
+	}
+	if len(history) == 0 {
+		return nil, fmt.Errorf("the user %s does not exist", id)
+	}
+
+	return history, nil
+}
+
+// GetAllUsers returns every user, skipping deactivated users unless includeDeactivated is set
+func (s *SmartContract) GetAllUsers(ctx contractapi.TransactionContextInterface, includeDeactivated bool) ([]*User, error) {
 	fmt.Println("function GetAllUsers")
 	var users []*User
 	//TODO GetAllUsers
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(userPrefix, []string{})
 	if err != nil {
 		return nil, err
 	}
@@ -164,60 +490,254 @@ func (s *SmartContract) GetAllUsers(ctx contractapi.TransactionContextInterface)
 		if err != nil {
 			return nil, err
 		}
+		if user.Deactivated && !includeDeactivated {
+			continue
+		}
 		users = append(users, &user)
 	}
 
 	return users, nil
 }
 
-func (s *SmartContract) CreateTransaction(ctx contractapi.TransactionContextInterface, userId string, hash string, amount string, currency string, date string, bankId string) (bool, error) {
-	user, err := s.GetUser(ctx, userId)
+// GetUsersPage returns one page of users, starting at the given bookmark.
+// Deactivated users are skipped unless includeDeactivated is set, so a page
+// may hold fewer records than were fetched.
+func (s *SmartContract) GetUsersPage(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string, includeDeactivated bool) (*UserPage, error) {
+	fmt.Println("function GetUsersPage")
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(userPrefix, []string{}, pageSize, bookmark)
 	if err != nil {
-		return false, err
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	users := []*User{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var user User
+		err = json.Unmarshal(queryResponse.Value, &user)
+		if err != nil {
+			return nil, err
+		}
+		if user.Deactivated && !includeDeactivated {
+			continue
+		}
+		users = append(users, &user)
+	}
+
+	return &UserPage{
+		Records:             users,
+		Bookmark:            metadata.Bookmark,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+// CreateTransaction records a transaction of the user processed by the bank.
+// Transactions are keyed by hash, so retrying is safe: resubmitting the same
+// transaction changes nothing and reports a duplicate, while reusing a hash
+// for a different transaction is rejected. An empty date on a retry matches
+// the date recorded the first time.
+func (s *SmartContract) CreateTransaction(ctx contractapi.TransactionContextInterface, userId string, hash string, amount string, currency string, date string, bankId string) (*TransactionResult, error) {
+	fmt.Println("function CreateTransaction")
+	err := checkAccess(ctx, "CreateTransaction")
+	if err != nil {
+		return nil, err
+	}
+	err = validateHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	currency, err = parseCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+	minorUnits, err := parseAmount(amount, currency)
+	if err != nil {
+		return nil, err
+	}
+	transactionDate, err := parseDate(ctx, date)
+	if err != nil {
+		return nil, err
 	}
 
 	var transaction Transaction = Transaction{
+		UserId:    userId,
 		Hash:      hash,
-		Amount:    amount,
+		Amount:    formatAmount(minorUnits, currency),
 		Currency:  currency,
-		Date:      date,
+		Date:      transactionDate.Format(time.RFC3339),
+		BankId:    bankId,
+	}
+	existing, err := readTransactionByHash(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		if !isRetry(transaction, existing, date == "") {
+			return nil, fmt.Errorf("the transaction %s already exists with a different payload", hash)
+		}
+		return &TransactionResult{Status: transactionDuplicate, Transaction: existing}, nil
 	}
-	user.Transactions = append(user.Transactions, transaction)
 
-	userJson, err := json.Marshal(user)
+	_, err = s.GetUser(ctx, userId)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	bank, err := s.GetBankByID(ctx, bankId)
+	if err != nil {
+		return nil, err
+	}
+	if bank.Status != bankActive {
+		return nil, fmt.Errorf("the bank %s is deactivated", bankId)
 	}
 
-	ctx.GetStub().PutState(userId, userJson)
+	balance, err := updateBalance(ctx, userId, currency, minorUnits)
+	if err != nil {
+		return nil, err
+	}
+	err = putTransaction(ctx, &transaction)
+	if err != nil {
+		return nil, err
+	}
 
 	var transactionHashMapUserId TransactionHashMapUserId = TransactionHashMapUserId{
 		UserId:	userId,
 	}
+	hashKey, err := transactionHashKey(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	err = putJSON(ctx, hashKey, transactionHashMapUserId)
+	if err != nil {
+		return nil, err
+	}
 
-	transactionHashMapJson, err := json.Marshal(transactionHashMapUserId)
+	bank.TransactionCount++
+	err = putBank(ctx, bank)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	err = recordBankStats(ctx, &transaction)
+	if err != nil {
+		return nil, err
 	}
 
-	ctx.GetStub().PutState(hash, transactionHashMapJson)
+	err = emitEvents(ctx, "CreateTransaction",
+		EntityEvent{Type: TransactionCreated, EntityID: hash, Data: transaction},
+		EntityEvent{Type: BalanceUpdated, EntityID: userId + "/" + currency, Data: balance},
+		EntityEvent{Type: BankUpdated, EntityID: bankId, Data: bank},
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &TransactionResult{Status: transactionCreated, Transaction: &transaction}, nil
+}
 
-	bank, err := s.GetBankByID(ctx, bankId)
-	bank.TransactionCount++
-	bankJson, err := json.Marshal(bank)
+// GetUserTransactions returns one page of the user's transaction history
+func (s *SmartContract) GetUserTransactions(ctx contractapi.TransactionContextInterface, userId string, pageSize int32, bookmark string) (*TransactionPage, error) {
+	fmt.Println("function GetUserTransactions")
+	exists, err := s.UserExists(ctx, userId)
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the user %s does not exist", userId)
 	}
 
-	ctx.GetStub().PutState(bankPrefix + bankId, bankJson)
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(transactionPrefix, []string{userId}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer resultsIterator.Close()
 
-	return true, nil
+	transactions := []*Transaction{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var transaction Transaction
+		err = json.Unmarshal(queryResponse.Value, &transaction)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, &transaction)
+	}
+
+	return &TransactionPage{
+		Records:             transactions,
+		Bookmark:            metadata.Bookmark,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+	}, nil
+}
+
+// GetTransactionsByBank returns one page of the transactions processed by the bank
+func (s *SmartContract) GetTransactionsByBank(ctx contractapi.TransactionContextInterface, bankId string, pageSize int32, bookmark string) (*TransactionPage, error) {
+	fmt.Println("function GetTransactionsByBank")
+	exists, err := s.BankExists(ctx, bankId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the bank %s does not exist", bankId)
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(bankTransactionPrefix, []string{bankId}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	transactions := []*Transaction{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split the composite key %s: %v", queryResponse.Key, err)
+		}
+		key, err := transactionKey(ctx, attributes[1], attributes[2])
+		if err != nil {
+			return nil, err
+		}
+		transactionJson, err := ctx.GetStub().GetState(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read from world state: %v", err)
+		}
+		if transactionJson == nil {
+			return nil, fmt.Errorf("the transaction %s indexed for bank %s does not exist", attributes[2], bankId)
+		}
+
+		var transaction Transaction
+		err = json.Unmarshal(transactionJson, &transaction)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, &transaction)
+	}
+
+	return &TransactionPage{
+		Records:             transactions,
+		Bookmark:            metadata.Bookmark,
+		FetchedRecordsCount: metadata.FetchedRecordsCount,
+	}, nil
 }
 
 func (s *SmartContract) GetUserByTransactionHash(ctx contractapi.TransactionContextInterface, hash string) (*User, error) {
 	fmt.Println("function GetUser")
 	//TODO GetUser
-	transactionHashMapUserIdJson, err := ctx.GetStub().GetState(hash)
+	key, err := transactionHashKey(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	transactionHashMapUserIdJson, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
 	}
@@ -237,7 +757,11 @@ func (s *SmartContract) GetUserByTransactionHash(ctx contractapi.TransactionCont
 }
 
 func (s *SmartContract) GetBankByID(ctx contractapi.TransactionContextInterface, bankId string) (*Bank, error) {
-	bankJson, err := ctx.GetStub().GetState(bankPrefix + bankId)
+	key, err := bankKey(ctx, bankId)
+	if err != nil {
+		return nil, err
+	}
+	bankJson, err := ctx.GetStub().GetState(key)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read from world state: %v", err)
 	}
@@ -250,12 +774,20 @@ func (s *SmartContract) GetBankByID(ctx contractapi.TransactionContextInterface,
 	if err != nil {
 		return nil, err
 	}
+	// Banks created before statuses were introduced are active
+	if bank.Status == "" {
+		bank.Status = bankActive
+	}
 	return &bank, nil
 }
 
 func (s *SmartContract) BankExists(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
 	//TODO UserExists
-	assetJSON, err := ctx.GetStub().GetState(bankPrefix + id)
+	key, err := bankKey(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	assetJSON, err := ctx.GetStub().GetState(key)
 	fmt.Println("function UserExists")
 	if err != nil {
 		return false, fmt.Errorf("failed to read from world state: %v", err)
@@ -263,24 +795,233 @@ func (s *SmartContract) BankExists(ctx contractapi.TransactionContextInterface,
 	return assetJSON != nil, nil
 }
 
-func (s *SmartContract) CreateBank(ctx contractapi.TransactionContextInterface, bankId string, name string) error {
-	exists, err := s.BankExists(ctx, bankId)
+func (s *SmartContract) CreateBank(ctx contractapi.TransactionContextInterface, bankId string, name string, swiftCode string, country string) error {
+	err := checkAccess(ctx, "CreateBank")
 	if err != nil {
 		return err
 	}
-	if exists {
-		return fmt.Errorf("the bank %s already exists", bankId)
+	bank, err := newBank(ctx, bankId, name, swiftCode, country)
+	if err != nil {
+		return err
 	}
+	err = putBank(ctx, bank)
+	if err != nil {
+		return err
+	}
+	return emitEvents(ctx, "CreateBank", EntityEvent{Type: BankCreated, EntityID: bankId, Data: bank})
+}
+
+// Helper Functions
 
-	bank := Bank{
-		ID: bankId,
-		Name: name,
-		TransactionCount: 0,
+// readUser returns the user whether or not it is deactivated
+func readUser(ctx contractapi.TransactionContextInterface, id string) (*User, error) {
+	key, err := userKey(ctx, id)
+	if err != nil {
+		return nil, err
 	}
-	bankJson, err := json.Marshal(bank)
+	userJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if userJson == nil {
+		return nil, fmt.Errorf("the user %s does not exist", id)
+	}
+
+	var user User
+	err = json.Unmarshal(userJson, &user)
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// txTime returns the timestamp of the transaction proposal in UTC
+func txTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC(), nil
+}
+
+// validateHash checks the hash of a transaction submitted by a client, which
+// must not end like the hashes of the entries written by ReverseTransaction
+// and TransferBetweenUsers, or it could take the place of one
+func validateHash(hash string) error {
+	if hash == "" {
+		return &ValidationError{Field: "hash", Value: hash, Reason: "must not be empty"}
+	}
+	for _, suffix := range []string{reversalSuffix, debitSuffix, creditSuffix} {
+		if strings.HasSuffix(hash, suffix) {
+			return &ValidationError{Field: "hash", Value: hash, Reason: fmt.Sprintf("must not end with %s, which is reserved", suffix)}
+		}
+	}
+	return nil
+}
+
+// checkNotPurged fails if PurgeUser has purged a user with the id
+func checkNotPurged(ctx contractapi.TransactionContextInterface, id string) error {
+	key, err := createKey(ctx, purgedUserPrefix, id)
+	if err != nil {
+		return err
+	}
+	tombstoneJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if tombstoneJson != nil {
+		return fmt.Errorf("the user %s was purged and its id cannot be reused", id)
+	}
+	return nil
+}
+
+// checkHashNotPurged fails if PurgeUser has purged the user of a transaction
+// with the hash
+func checkHashNotPurged(ctx contractapi.TransactionContextInterface, hash string) error {
+	key, err := createKey(ctx, purgedTransactionHashPrefix, hash)
+	if err != nil {
+		return err
+	}
+	tombstoneJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if tombstoneJson == nil {
+		return nil
+	}
+	var tombstone PurgedTransactionHash
+	err = json.Unmarshal(tombstoneJson, &tombstone)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("the transaction %s belongs to the purged user %s and its hash cannot be reused", hash, tombstone.UserId)
+}
+
+// userKey returns the user~id key of a user
+func userKey(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return createKey(ctx, userPrefix, id)
+}
+
+// transactionKey returns the txn~userId~hash key of a transaction
+func transactionKey(ctx contractapi.TransactionContextInterface, userId string, hash string) (string, error) {
+	return createKey(ctx, transactionPrefix, userId, hash)
+}
+
+// transactionHashKey returns the txhash~hash key mapping a transaction to its user
+func transactionHashKey(ctx contractapi.TransactionContextInterface, hash string) (string, error) {
+	return createKey(ctx, transactionHashPrefix, hash)
+}
+
+// bankKey returns the bank~id key of a bank
+func bankKey(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	return createKey(ctx, bankPrefix, id)
+}
+
+func createKey(ctx contractapi.TransactionContextInterface, objectType string, attributes ...string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		return "", fmt.Errorf("failed to create the composite key for prefix %s: %v", objectType, err)
+	}
+	return key, nil
+}
+
+// putJSON stores the JSON encoding of value under key
+func putJSON(ctx contractapi.TransactionContextInterface, key string, value interface{}) error {
+	valueJson, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	err = ctx.GetStub().PutState(key, valueJson)
+	if err != nil {
+		return fmt.Errorf("failed to put to world state: %v", err)
+	}
+	return nil
+}
+
+// readTransactionByHash returns the transaction recorded under hash, or nil
+// if there is none. It fails for the hashes of purged users' transactions.
+func readTransactionByHash(ctx contractapi.TransactionContextInterface, hash string) (*Transaction, error) {
+	hashKey, err := transactionHashKey(ctx, hash)
+	if err != nil {
+		return nil, err
+	}
+	transactionHashMapJson, err := ctx.GetStub().GetState(hashKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if transactionHashMapJson == nil {
+		return nil, checkHashNotPurged(ctx, hash)
+	}
+	var transactionHashMapUserId TransactionHashMapUserId
+	err = json.Unmarshal(transactionHashMapJson, &transactionHashMapUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := transactionKey(ctx, transactionHashMapUserId.UserId, hash)
+	if err != nil {
+		return nil, err
+	}
+	transactionJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if transactionJson == nil {
+		return nil, fmt.Errorf("the transaction %s of user %s is missing", hash, transactionHashMapUserId.UserId)
+	}
+	var transaction Transaction
+	err = json.Unmarshal(transactionJson, &transaction)
+	if err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// isRetry reports whether transaction repeats the recorded existing one. A
+// transaction without a date matches the recorded date.
+func isRetry(transaction Transaction, existing *Transaction, noDate bool) bool {
+	if noDate {
+		transaction.Date = existing.Date
+	}
+	transaction.DocType = existing.DocType
+	transaction.ReversedBy = existing.ReversedBy
+	return transaction == *existing
+}
+
+// putUser stores the user under its user~id key
+func putUser(ctx contractapi.TransactionContextInterface, user *User) error {
+	key, err := userKey(ctx, user.ID)
 	if err != nil {
 		return err
 	}
-	ctx.GetStub().PutState(bankPrefix + bankId, bankJson)
-	return nil	
-}
\ No newline at end of file
+	user.DocType = userDocType
+	return putJSON(ctx, key, user)
+}
+
+// putTransaction stores the transaction under its txn~userId~hash key and,
+// when it was processed by a bank, indexes it under bankTxn~bankId~userId~hash
+func putTransaction(ctx contractapi.TransactionContextInterface, transaction *Transaction) error {
+	key, err := transactionKey(ctx, transaction.UserId, transaction.Hash)
+	if err != nil {
+		return err
+	}
+	transaction.DocType = transactionDocType
+	err = putJSON(ctx, key, transaction)
+	if err != nil {
+		return err
+	}
+	if transaction.BankId == "" {
+		return nil
+	}
+
+	indexKey, err := createKey(ctx, bankTransactionPrefix, transaction.BankId, transaction.UserId, transaction.Hash)
+	if err != nil {
+		return err
+	}
+	// Index entries only need their key, so store a null byte as the value
+	err = ctx.GetStub().PutState(indexKey, []byte{0x00})
+	if err != nil {
+		return fmt.Errorf("failed to put to world state: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// BankDailyStats aggregates the transactions a bank processed in one currency
+// on one UTC day. Volume is the sum of their absolute amounts, and Min and Max
+// are their smallest and largest signed amounts. Compensating entries written
+// by ReverseTransaction are counted on the day of the reversal.
+type BankDailyStats struct {
+	BankId   string `json:"bank_id"`
+	Date     string `json:"date"`
+	Currency string `json:"currency"`
+	Count    int    `json:"count"`
+	Volume   string `json:"volume"`
+	Min      string `json:"min"`
+	Max      string `json:"max"`
+}
+
+// Define objectType names for prefix
+const bankStatsPrefix = "bankStats"
+
+// GetBankStats returns the daily statistics of the bank from fromDate to
+// toDate, both YYYY-MM-DD and inclusive, ordered by day and currency. Days
+// without transactions are left out.
+func (s *SmartContract) GetBankStats(ctx contractapi.TransactionContextInterface, bankId string, fromDate string, toDate string) ([]*BankDailyStats, error) {
+	fmt.Println("function GetBankStats")
+	exists, err := s.BankExists(ctx, bankId)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("the bank %s does not exist", bankId)
+	}
+	_, err = time.Parse(dayLayout, fromDate)
+	if err != nil {
+		return nil, &ValidationError{Field: "from_date", Value: fromDate, Reason: "not a YYYY-MM-DD date"}
+	}
+	_, err = time.Parse(dayLayout, toDate)
+	if err != nil {
+		return nil, &ValidationError{Field: "to_date", Value: toDate, Reason: "not a YYYY-MM-DD date"}
+	}
+	if fromDate > toDate {
+		return nil, &ValidationError{Field: "to_date", Value: toDate, Reason: "must not be before from_date"}
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(bankStatsPrefix, []string{bankId})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	statistics := []*BankDailyStats{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var stats BankDailyStats
+		err = json.Unmarshal(queryResponse.Value, &stats)
+		if err != nil {
+			return nil, err
+		}
+		if stats.Date < fromDate {
+			continue
+		}
+		if stats.Date > toDate {
+			break
+		}
+		statistics = append(statistics, &stats)
+	}
+
+	return statistics, nil
+}
+
+// recordBankStats adds the transaction to the statistics of its bank
+func recordBankStats(ctx contractapi.TransactionContextInterface, transaction *Transaction) error {
+	day, err := transactionDay(transaction)
+	if err != nil {
+		return err
+	}
+	stats, err := readBankStats(ctx, transaction.BankId, day, transaction.Currency)
+	if err != nil {
+		return err
+	}
+	err = addToBankStats(stats, transaction.Amount)
+	if err != nil {
+		return err
+	}
+	return putBankStats(ctx, stats)
+}
+
+// readBankStats returns the statistics of the bank for the day and currency,
+// which are empty if the bank processed no such transaction yet
+func readBankStats(ctx contractapi.TransactionContextInterface, bankId string, day string, currency string) (*BankDailyStats, error) {
+	key, err := createKey(ctx, bankStatsPrefix, bankId, day, currency)
+	if err != nil {
+		return nil, err
+	}
+	statsJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if statsJson == nil {
+		zero := formatAmount(0, currency)
+		return &BankDailyStats{BankId: bankId, Date: day, Currency: currency, Volume: zero, Min: zero, Max: zero}, nil
+	}
+
+	var stats BankDailyStats
+	err = json.Unmarshal(statsJson, &stats)
+	if err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// addToBankStats adds an amount in the currency of the statistics
+func addToBankStats(stats *BankDailyStats, amount string) error {
+	minorUnits, err := parseDecimal(amount, stats.Currency)
+	if err != nil {
+		return err
+	}
+	volume, err := parseDecimal(stats.Volume, stats.Currency)
+	if err != nil {
+		return fmt.Errorf("the statistics of bank %s on %s are corrupted: %v", stats.BankId, stats.Date, err)
+	}
+	min, err := parseDecimal(stats.Min, stats.Currency)
+	if err != nil {
+		return fmt.Errorf("the statistics of bank %s on %s are corrupted: %v", stats.BankId, stats.Date, err)
+	}
+	max, err := parseDecimal(stats.Max, stats.Currency)
+	if err != nil {
+		return fmt.Errorf("the statistics of bank %s on %s are corrupted: %v", stats.BankId, stats.Date, err)
+	}
+
+	magnitude := minorUnits
+	if magnitude < 0 {
+		magnitude = -magnitude
+	}
+	if volume > math.MaxInt64-magnitude {
+		return fmt.Errorf("the volume of bank %s on %s in %s would overflow", stats.BankId, stats.Date, stats.Currency)
+	}
+	if stats.Count == 0 || minorUnits < min {
+		min = minorUnits
+	}
+	if stats.Count == 0 || minorUnits > max {
+		max = minorUnits
+	}
+	stats.Count++
+	stats.Volume = formatAmount(volume+magnitude, stats.Currency)
+	stats.Min = formatAmount(min, stats.Currency)
+	stats.Max = formatAmount(max, stats.Currency)
+	return nil
+}
+
+// putBankStats stores the statistics under their bankStats~bankId~day~currency key
+func putBankStats(ctx contractapi.TransactionContextInterface, stats *BankDailyStats) error {
+	key, err := createKey(ctx, bankStatsPrefix, stats.BankId, stats.Date, stats.Currency)
+	if err != nil {
+		return err
+	}
+	return putJSON(ctx, key, stats)
+}
+
+// transactionDay returns the UTC day of the transaction date
+func transactionDay(transaction *Transaction) (string, error) {
+	date, err := time.Parse(time.RFC3339, transaction.Date)
+	if err != nil {
+		return "", fmt.Errorf("the transaction %s is corrupted: %v", transaction.Hash, err)
+	}
+	return date.UTC().Format(dayLayout), nil
+}
@@ -0,0 +1,157 @@
+package smartcontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Transfer moves an amount from one user to another. It is booked as a debit
+// entry of the sender and a credit entry of the recipient, whose hashes are
+// the transfer ID followed by ":debit" and ":credit".
+type Transfer struct {
+	ID         string `json:"id"`
+	FromUserId string `json:"from_user_id"`
+	ToUserId   string `json:"to_user_id"`
+	Amount     string `json:"amount"`
+	Currency   string `json:"currency"`
+	Reference  string `json:"reference"`
+	Date       string `json:"date"`
+}
+
+// Define objectType names for prefix
+const transferPrefix = "transfer"
+
+// Suffixes appended to the transfer ID to form the hashes of its entries
+const debitSuffix = ":debit"
+const creditSuffix = ":credit"
+
+// TransferBetweenUsers moves a positive amount of currency from fromId to
+// toId. Both users must be active, and the debit is subject to overdraft
+// protection. The transfer ID is the ID of the Fabric transaction, so both
+// entries and balances are written by the same transaction or not at all.
+func (s *SmartContract) TransferBetweenUsers(ctx contractapi.TransactionContextInterface, fromId string, toId string, amount string, currency string, reference string) (*Transfer, error) {
+	fmt.Println("function TransferBetweenUsers")
+	err := checkAccess(ctx, "TransferBetweenUsers")
+	if err != nil {
+		return nil, err
+	}
+	if fromId == toId {
+		return nil, &ValidationError{Field: "to_user_id", Value: toId, Reason: "must differ from the sender"}
+	}
+	_, err = s.GetUser(ctx, fromId)
+	if err != nil {
+		return nil, err
+	}
+	_, err = s.GetUser(ctx, toId)
+	if err != nil {
+		return nil, err
+	}
+	currency, err = parseCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+	minorUnits, err := parseAmount(amount, currency)
+	if err != nil {
+		return nil, err
+	}
+	if minorUnits < 0 {
+		return nil, &ValidationError{Field: "amount", Value: amount, Reason: "must be positive"}
+	}
+	date, err := txTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := Transfer{
+		ID:         ctx.GetStub().GetTxID(),
+		FromUserId: fromId,
+		ToUserId:   toId,
+		Amount:     formatAmount(minorUnits, currency),
+		Currency:   currency,
+		Reference:  reference,
+		Date:       date.Format(time.RFC3339),
+	}
+	key, err := createKey(ctx, transferPrefix, transfer.ID)
+	if err != nil {
+		return nil, err
+	}
+	transferJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if transferJson != nil {
+		return nil, fmt.Errorf("the transfer %s already exists", transfer.ID)
+	}
+
+	events := []EntityEvent{{Type: TransferCreated, EntityID: transfer.ID, Data: transfer}}
+	for _, entry := range []struct {
+		userId     string
+		side       string
+		minorUnits int64
+	}{{fromId, debitSuffix, -minorUnits}, {toId, creditSuffix, minorUnits}} {
+		balance, err := updateBalance(ctx, entry.userId, currency, entry.minorUnits)
+		if err != nil {
+			return nil, err
+		}
+		transaction := Transaction{
+			UserId:     entry.userId,
+			Hash:       transfer.ID + entry.side,
+			Amount:     formatAmount(entry.minorUnits, currency),
+			Currency:   currency,
+			Date:       transfer.Date,
+			TransferId: transfer.ID,
+		}
+		err = putTransaction(ctx, &transaction)
+		if err != nil {
+			return nil, err
+		}
+		hashKey, err := transactionHashKey(ctx, transaction.Hash)
+		if err != nil {
+			return nil, err
+		}
+		err = putJSON(ctx, hashKey, TransactionHashMapUserId{UserId: entry.userId})
+		if err != nil {
+			return nil, err
+		}
+		events = append(events,
+			EntityEvent{Type: TransactionCreated, EntityID: transaction.Hash, Data: transaction},
+			EntityEvent{Type: BalanceUpdated, EntityID: entry.userId + "/" + currency, Data: balance},
+		)
+	}
+
+	err = putJSON(ctx, key, transfer)
+	if err != nil {
+		return nil, err
+	}
+	err = emitEvents(ctx, "TransferBetweenUsers", events...)
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
+
+// GetTransfer returns the transfer with the given ID
+func (s *SmartContract) GetTransfer(ctx contractapi.TransactionContextInterface, transferId string) (*Transfer, error) {
+	fmt.Println("function GetTransfer")
+	key, err := createKey(ctx, transferPrefix, transferId)
+	if err != nil {
+		return nil, err
+	}
+	transferJson, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if transferJson == nil {
+		return nil, fmt.Errorf("the transfer %s does not exist", transferId)
+	}
+
+	var transfer Transfer
+	err = json.Unmarshal(transferJson, &transfer)
+	if err != nil {
+		return nil, err
+	}
+	return &transfer, nil
+}
@@ -0,0 +1,237 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// GetQueryResult evaluates the selector of a CouchDB query over the JSON
+// records of the mock state, standing in for a peer backed by CouchDB. It
+// supports the implicit equality, combination and comparison operators used
+// by the contract and its tests; sort, fields and use_index are ignored.
+func (stub *MockStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	keys := []string{}
+	for element := stub.Keys.Front(); element != nil; element = element.Next() {
+		keys = append(keys, element.Value.(string))
+	}
+	return queryState(stub.State, keys, query)
+}
+
+// GetPrivateDataQueryResult evaluates the selector over the records of the
+// private data collection, like GetQueryResult
+func (stub *MockStub) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	keys := []string{}
+	for key := range stub.PvtState[collection] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return queryState(stub.PvtState[collection], keys, query)
+}
+
+// queryState returns the JSON records of state under keys, in order, that
+// match the selector of query
+func queryState(state map[string][]byte, keys []string, query string) (shim.StateQueryIteratorInterface, error) {
+	var parsed struct {
+		Selector map[string]interface{} `json:"selector"`
+	}
+	err := json.Unmarshal([]byte(query), &parsed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid query %s: %v", query, err)
+	}
+
+	matches := &sliceIterator{}
+	for _, key := range keys {
+		var document map[string]interface{}
+		if json.Unmarshal(state[key], &document) != nil {
+			continue
+		}
+		matched, err := matchSelector(document, parsed.Selector)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			matches.records = append(matches.records, &queryresult.KV{Key: key, Value: state[key]})
+		}
+	}
+	return matches, nil
+}
+
+func (stub *MockStub) GetQueryResultWithPagination(query string, pageSize int32,
+	bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	iterator, err := stub.GetQueryResult(query)
+	if err != nil {
+		return nil, nil, err
+	}
+	return paginate(iterator, pageSize, bookmark)
+}
+
+// matchSelector reports whether document matches every condition of selector
+func matchSelector(document map[string]interface{}, selector map[string]interface{}) (bool, error) {
+	for field, condition := range selector {
+		var matched bool
+		var err error
+		switch field {
+		case "$and", "$or", "$nor":
+			matched, err = matchCombination(document, field, condition)
+		case "$not":
+			subSelector, ok := condition.(map[string]interface{})
+			if !ok {
+				return false, fmt.Errorf("$not takes a selector")
+			}
+			matched, err = matchSelector(document, subSelector)
+			matched = !matched
+		default:
+			value, found := lookupField(document, field)
+			matched, err = matchCondition(value, found, condition)
+		}
+		if err != nil || !matched {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func matchCombination(document map[string]interface{}, operator string, condition interface{}) (bool, error) {
+	selectors, ok := condition.([]interface{})
+	if !ok {
+		return false, fmt.Errorf("%s takes an array of selectors", operator)
+	}
+	for _, item := range selectors {
+		subSelector, ok := item.(map[string]interface{})
+		if !ok {
+			return false, fmt.Errorf("%s takes an array of selectors", operator)
+		}
+		matched, err := matchSelector(document, subSelector)
+		if err != nil {
+			return false, err
+		}
+		if matched && operator == "$or" {
+			return true, nil
+		}
+		if matched && operator == "$nor" {
+			return false, nil
+		}
+		if !matched && operator == "$and" {
+			return false, nil
+		}
+	}
+	return operator != "$or", nil
+}
+
+// matchCondition matches a field value against either a literal, meaning
+// equality, or an object of operators
+func matchCondition(value interface{}, found bool, condition interface{}) (bool, error) {
+	operators, ok := condition.(map[string]interface{})
+	if !ok || !isOperatorObject(operators) {
+		return found && reflect.DeepEqual(value, condition), nil
+	}
+
+	for operator, operand := range operators {
+		var matched bool
+		switch operator {
+		case "$exists":
+			matched = found == operand
+		case "$eq":
+			matched = found && reflect.DeepEqual(value, operand)
+		case "$ne":
+			matched = found && !reflect.DeepEqual(value, operand)
+		case "$gt", "$gte", "$lt", "$lte":
+			comparison, comparable := compareValues(value, operand)
+			matched = found && comparable &&
+				((operator == "$gt" && comparison > 0) || (operator == "$gte" && comparison >= 0) ||
+					(operator == "$lt" && comparison < 0) || (operator == "$lte" && comparison <= 0))
+		case "$in", "$nin":
+			candidates, ok := operand.([]interface{})
+			if !ok {
+				return false, fmt.Errorf("%s takes an array", operator)
+			}
+			in := false
+			for _, candidate := range candidates {
+				if reflect.DeepEqual(value, candidate) {
+					in = true
+				}
+			}
+			matched = found && in == (operator == "$in")
+		case "$regex":
+			pattern, ok := operand.(string)
+			if !ok {
+				return false, fmt.Errorf("$regex takes a string")
+			}
+			expression, err := regexp.Compile(pattern)
+			if err != nil {
+				return false, err
+			}
+			text, isText := value.(string)
+			matched = found && isText && expression.MatchString(text)
+		case "$not":
+			notMatched, err := matchCondition(value, found, operand)
+			if err != nil {
+				return false, err
+			}
+			matched = !notMatched
+		default:
+			return false, fmt.Errorf("the operator %s is not supported by the mock stub", operator)
+		}
+		if !matched {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func isOperatorObject(condition map[string]interface{}) bool {
+	for key := range condition {
+		if !strings.HasPrefix(key, "$") {
+			return false
+		}
+	}
+	return len(condition) > 0
+}
+
+// lookupField returns the value of a field, following dots into nested objects
+func lookupField(document map[string]interface{}, field string) (interface{}, bool) {
+	var value interface{} = document
+	for _, name := range strings.Split(field, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		value, ok = object[name]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// compareValues compares two numbers or two strings
+func compareValues(a interface{}, b interface{}) (int, bool) {
+	switch a := a.(type) {
+	case float64:
+		b, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		if a < b {
+			return -1, true
+		} else if a > b {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		b, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(a, b), true
+	}
+	return 0, false
+}
@@ -0,0 +1,280 @@
+package test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// MockStub extends shimtest.MockStub with the ledger queries it leaves
+// unimplemented, so that contract functions relying on them can be tested
+type MockStub struct {
+	*shimtest.MockStub
+	cc        shim.Chaincode
+	args      [][]byte
+	transient map[string][]byte
+
+	// Event is the chaincode event set by the last invocation, if any
+	Event *pb.ChaincodeEvent
+
+	history map[string][]*queryresult.KeyModification
+}
+
+func NewMockStub(name string, cc shim.Chaincode) *MockStub {
+	return &MockStub{
+		MockStub: shimtest.NewMockStub(name, cc),
+		cc:       cc,
+		history:  map[string][]*queryresult.KeyModification{},
+	}
+}
+
+// MockInvoke invokes the chaincode with this stub, so the overridden
+// queries below are the ones the contract sees
+func (stub *MockStub) MockInvoke(uuid string, args [][]byte) pb.Response {
+	return stub.MockInvokeWithTransient(uuid, args, nil)
+}
+
+// MockInvokeWithTransient invokes the chaincode with a transient map
+func (stub *MockStub) MockInvokeWithTransient(uuid string, args [][]byte, transient map[string][]byte) pb.Response {
+	stub.args = args
+	stub.transient = transient
+	stub.Event = nil
+	stub.MockTransactionStart(uuid)
+	res := stub.cc.Invoke(stub)
+	stub.MockTransactionEnd(uuid)
+	return res
+}
+
+func (stub *MockStub) GetArgs() [][]byte {
+	return stub.args
+}
+
+func (stub *MockStub) GetStringArgs() []string {
+	strargs := make([]string, 0, len(stub.args))
+	for _, barg := range stub.args {
+		strargs = append(strargs, string(barg))
+	}
+	return strargs
+}
+
+func (stub *MockStub) GetFunctionAndParameters() (function string, params []string) {
+	allargs := stub.GetStringArgs()
+	params = []string{}
+	if len(allargs) >= 1 {
+		function = allargs[0]
+		params = allargs[1:]
+	}
+	return
+}
+
+func (stub *MockStub) GetTransient() (map[string][]byte, error) {
+	return stub.transient, nil
+}
+
+// SetEvent keeps only the last event of a transaction, as the peer does
+func (stub *MockStub) SetEvent(name string, payload []byte) error {
+	stub.Event = &pb.ChaincodeEvent{EventName: name, Payload: payload}
+	return nil
+}
+
+// SetCreator makes the following invocations come from a client of mspId
+// whose certificate carries the given role attribute, as issued by Fabric CA
+func (stub *MockStub) SetCreator(mspId string, role string) {
+	creator, err := newCreator(mspId, role)
+	if err != nil {
+		panic(err)
+	}
+	stub.Creator = creator
+}
+
+// PutState records every write in the history of the key
+func (stub *MockStub) PutState(key string, value []byte) error {
+	err := stub.MockStub.PutState(key, value)
+	if err != nil {
+		return err
+	}
+	stub.recordHistory(key, value)
+	return nil
+}
+
+// DelState records deletions in the history of the key
+func (stub *MockStub) DelState(key string) error {
+	err := stub.MockStub.DelState(key)
+	if err != nil {
+		return err
+	}
+	stub.recordHistory(key, nil)
+	return nil
+}
+
+func (stub *MockStub) DelPrivateData(collection string, key string) error {
+	delete(stub.PvtState[collection], key)
+	return nil
+}
+
+// GetHistoryForKey returns the writes to key, newest first, as the peer does
+func (stub *MockStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	modifications := stub.history[key]
+	newestFirst := make([]*queryresult.KeyModification, len(modifications))
+	for i, modification := range modifications {
+		newestFirst[len(modifications)-1-i] = modification
+	}
+	return &historyIterator{modifications: newestFirst}, nil
+}
+
+func (stub *MockStub) recordHistory(key string, value []byte) {
+	stub.history[key] = append(stub.history[key], &queryresult.KeyModification{
+		TxId:      stub.TxID,
+		Value:     value,
+		Timestamp: stub.TxTimestamp,
+		IsDelete:  len(value) == 0,
+	})
+}
+
+// GetStateByRange treats empty keys as open ends of the simple key range,
+// so composite keys are skipped as they are on the peer
+func (stub *MockStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if startKey == "" {
+		startKey = "\x01"
+	}
+	if endKey == "" {
+		endKey = string(utf8.MaxRune)
+	}
+	return stub.MockStub.GetStateByRange(startKey, endKey)
+}
+
+func (stub *MockStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32,
+	bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	iterator, err := stub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return paginate(iterator, pageSize, bookmark)
+}
+
+func (stub *MockStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string,
+	pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	iterator, err := stub.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	return paginate(iterator, pageSize, bookmark)
+}
+
+// paginate reads one page from iterator, starting at the bookmark key. The
+// returned bookmark is the key of the first record of the next page.
+func paginate(iterator shim.StateQueryIteratorInterface, pageSize int32,
+	bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	defer iterator.Close()
+
+	page := &sliceIterator{}
+	metadata := &pb.QueryResponseMetadata{}
+	for iterator.HasNext() {
+		kv, err := iterator.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if bookmark != "" && kv.Key < bookmark {
+			continue
+		}
+		if pageSize > 0 && int32(len(page.records)) == pageSize {
+			metadata.Bookmark = kv.Key
+			break
+		}
+		page.records = append(page.records, kv)
+	}
+	metadata.FetchedRecordsCount = int32(len(page.records))
+	return page, metadata, nil
+}
+
+// sliceIterator iterates over query results held in memory
+type sliceIterator struct {
+	records []*queryresult.KV
+	current int
+}
+
+func (iter *sliceIterator) HasNext() bool {
+	return iter.current < len(iter.records)
+}
+
+func (iter *sliceIterator) Next() (*queryresult.KV, error) {
+	kv := iter.records[iter.current]
+	iter.current++
+	return kv, nil
+}
+
+func (iter *sliceIterator) Close() error {
+	return nil
+}
+
+// historyIterator iterates over the history of a key held in memory
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+	current       int
+}
+
+func (iter *historyIterator) HasNext() bool {
+	return iter.current < len(iter.modifications)
+}
+
+func (iter *historyIterator) Next() (*queryresult.KeyModification, error) {
+	modification := iter.modifications[iter.current]
+	iter.current++
+	return modification, nil
+}
+
+func (iter *historyIterator) Close() error {
+	return nil
+}
+
+// attributesOID is the certificate extension Fabric CA stores attributes in
+var attributesOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}
+
+// newCreator returns a serialized identity with a self-signed certificate
+func newCreator(mspId string, role string) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := map[string]map[string]string{"attrs": {}}
+	if role != "" {
+		attributes["attrs"]["role"] = role
+	}
+	attributesJson, err := json.Marshal(attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "user@" + mspId},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: attributesOID, Value: attributesJson}},
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspId,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate}),
+	})
+}
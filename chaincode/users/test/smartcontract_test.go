@@ -11,48 +11,62 @@ import (
 	"testing"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim"
-	"github.com/hyperledger/fabric-chaincode-go/shimtest"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/stretchr/testify/assert"
 )
 
-var Stub *shimtest.MockStub
+var Stub *MockStub
 var Scc *contractapi.ContractChaincode
-var user1 smartcontract.User = smartcontract.User{
+var user1 smartcontract.UserPII = smartcontract.UserPII{
 	ID:    "1",
 	Name:  "John Lee",
 	Email: "john.lee@g.com",
 }
-var user2 smartcontract.User = smartcontract.User{
+var user2 smartcontract.UserPII = smartcontract.UserPII{
 	ID:    "2",
 	Name:  "Amy Lin",
 	Email: "amy.lin@g.com",
 }
 
+// testSalt is the salt the tests hash personal data with
+const testSalt = "6f1c2a9e8b7d4c3f"
+
 var transaction1 smartcontract.Transaction = smartcontract.Transaction{
 	Hash:      "0x000000001",
 	Amount:    "200",
 	Currency:  "USD",
-	Date:      "2022-04-14",
+	Date:      "2022-04-14T09:30:00Z",
 }
 
 var transaction2 smartcontract.Transaction = smartcontract.Transaction{
 	Hash:      "0x000000002",
 	Amount:    "500",
-	Currency:  "NTD",
-	Date:      "2022-04-16",
+	Currency:  "TWD",
+	Date:      "2022-04-16T14:00:00+08:00",
 }
 
 var bank smartcontract.Bank = smartcontract.Bank{
 	ID: "04231910",
 	Name: "國泰世華商業銀行",
 	TransactionCount: 0,
+	SwiftCode: "UWCBTWTP",
+	Country: "TW",
+}
+
+var fubonBank smartcontract.Bank = smartcontract.Bank{
+	ID: "03750168",
+	Name: "台北富邦商業銀行",
+	TransactionCount: 0,
+	SwiftCode: "TPBKTWTP",
+	Country: "TW",
 }
 
 var testbank smartcontract.Bank = smartcontract.Bank{
 	ID: "123456",
 	Name: "Test bank",
 	TransactionCount: 0,
+	SwiftCode: "TESTTWTP001",
+	Country: "TW",
 }
 
 
@@ -72,7 +86,16 @@ func NewStub() {
 		log.Println("NewChaincode failed", err)
 		os.Exit(0)
 	}
-	Stub = shimtest.NewMockStub("main", Scc)
+	Stub = NewMockStub("main", Scc)
+	Stub.SetCreator("Org1MSP", "admin,teller")
+
+	// The first InitLedger makes Org1MSP admin and trusts the roles in its
+	// certificates
+	res := Stub.MockInvoke("uuid", [][]byte{[]byte("InitLedger"), []byte("[]")})
+	if res.Status != shim.OK {
+		log.Println("InitLedger failed", res.Message)
+		os.Exit(0)
+	}
 }
 
 func Test_CreateUser(t *testing.T) {
@@ -117,8 +140,15 @@ func Test_GetUser(t *testing.T) {
 	}
 	fmt.Println("userJson: ", userJson)
 	assert.Equal(t, userJson.ID, user1.ID)
-	assert.Equal(t, userJson.Name, user1.Name)
-	assert.Equal(t, userJson.Email, user1.Email)
+	assert.Equal(t, len(userJson.NameHash), 64)
+	assert.NotEqual(t, userJson.NameHash, userJson.EmailHash)
+
+	pii, err := MockGetUserPII(user1.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, pii.Name, user1.Name)
+	assert.Equal(t, pii.Email, user1.Email)
 }
 
 func Test_UpdateUser(t *testing.T) {
@@ -130,17 +160,56 @@ func Test_UpdateUser(t *testing.T) {
 		t.FailNow()
 	}
 
-	MockUpdateUser(user1.ID, "change name", "change email")
+	MockUpdateUser(user1.ID, "change name", "change.email@g.com")
 
-	userJson, err := MockGetUser(user1.ID)
+	userJson, err := MockGetUserPII(user1.ID)
 	if err != nil {
 		fmt.Println("get User", err)
 	}
 	fmt.Println("userJson: ", userJson)
 	assert.Equal(t, userJson.ID, user1.ID)
 	assert.Equal(t, userJson.Name, "change name")
-	assert.Equal(t, userJson.Email, "change email")
+	assert.Equal(t, userJson.Email, "change.email@g.com")
+
+}
+
+func Test_UniqueEmail(t *testing.T) {
+	fmt.Println("Test_UniqueEmail-----------------")
+	NewStub()
+	err := MockCreateUser(user1.ID, user1.Name, user1.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.NotNil(t, MockCreateUser(user2.ID, user2.Name, "not an email"))
+	assert.NotNil(t, MockCreateUser(user2.ID, user2.Name, "Amy Lin <amy.lin@g.com>"))
+	assert.NotNil(t, MockCreateUser(user2.ID, user2.Name, "JOHN.LEE@g.com"))
+	exists, _ := MockUserExists(user2.ID)
+	assert.False(t, exists)
+
+	err = MockCreateUser(user2.ID, user2.Name, user2.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.NotNil(t, MockUpdateUser(user2.ID, user2.Name, user1.Email))
+	assert.NotNil(t, MockUpdateUser(user2.ID, user2.Name, "amy.lin@"))
+	assert.Nil(t, MockUpdateUser(user2.ID, "Amy Chen", "AMY.LIN@g.com"))
+
+	// Changing the email frees the old one
+	assert.Nil(t, MockUpdateUser(user1.ID, user1.Name, "john.lee@work.com"))
+	user, err := MockGetUserByEmail("john.lee@work.com")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, user.ID, user1.ID)
+	_, err = MockGetUserByEmail(user1.Email)
+	assert.NotNil(t, err)
+	assert.Nil(t, MockCreateUser("3", "Ken Wu", user1.Email))
 
+	// Deactivated users keep their email until they are purged
+	MockDeleteUser("3", "account closed")
+	assert.NotNil(t, MockCreateUser("4", "Ken Lee", user1.Email))
+	assert.Nil(t, MockPurgeUser("3"))
+	assert.Nil(t, MockCreateUser("4", "Ken Lee", user1.Email))
 }
 
 func Test_DeleteUser(t *testing.T) {
@@ -151,32 +220,354 @@ func Test_DeleteUser(t *testing.T) {
 		t.FailNow()
 	}
 
-	MockDeleteUser(user1.ID)
+	err = MockDeleteUser(user1.ID, "account closed")
+	assert.Nil(t, err)
+	assert.NotNil(t, MockDeleteUser(user1.ID, "account closed"))
+
+	_, err = MockGetUser(user1.ID)
+	assert.NotNil(t, err)
+	exists, err := MockUserExists(user1.ID)
+	assert.Nil(t, err)
+	assert.True(t, exists)
+	_, err = MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	assert.NotNil(t, err)
+
+	users, err := MockGetAllUsers(false)
+	assert.Nil(t, err)
+	assert.Equal(t, len(users), 0)
+	users, err = MockGetAllUsers(true)
+	assert.Nil(t, err)
+	assert.Equal(t, len(users), 1)
+	assert.True(t, users[0].Deactivated)
+	assert.Equal(t, users[0].DeactivationReason, "account closed")
+	assert.NotEqual(t, users[0].DeactivatedAt, "")
+}
+
+func Test_RestoreUser(t *testing.T) {
+	fmt.Println("Test_RestoreUser-----------------")
+	NewStub()
+	err := MockCreateUser(user1.ID, user1.Name, user1.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.NotNil(t, MockRestoreUser(user1.ID))
+
+	MockDeleteUser(user1.ID, "account closed")
+	err = MockRestoreUser(user1.ID)
+	assert.Nil(t, err)
+	envelope := LastEvent(t, "UserRestored")
+	assert.Equal(t, envelope.Events[0].EntityID, user1.ID)
 
 	userJson, err := MockGetUser(user1.ID)
 	if err != nil {
-		fmt.Println("get User", err)
+		t.FailNow()
 	}
-	fmt.Println(userJson)
-	// assert.Equal(t, err, errors.New("GetUser error"))
+	assert.False(t, userJson.Deactivated)
+	assert.Equal(t, userJson.DeactivationReason, "")
+}
+
+func Test_PurgeUser(t *testing.T) {
+	fmt.Println("Test_PurgeUser-----------------")
+	NewStub()
+	MockInitLedger()
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+
+	Stub.SetCreator("Org2MSP", "bank-admin")
+	assert.NotNil(t, MockPurgeUser(user1.ID))
+
+	Stub.SetCreator("Org1MSP", "")
+	err := MockPurgeUser(user1.ID)
+	assert.Nil(t, err)
+	envelope := LastEvent(t, "UserDeleted")
+	assert.Equal(t, len(envelope.Events), 2)
+	assert.Equal(t, envelope.Events[1].Type, "TransactionHashDeleted")
+	assert.Equal(t, envelope.Events[1].EntityID, transaction1.Hash)
+
+	exists, err := MockUserExists(user1.ID)
+	assert.Nil(t, err)
+	assert.False(t, exists)
+	_, err = MockGetUserByTransactionHash(transaction1.Hash)
+	assert.NotNil(t, err)
+
+	// The bank keeps its record of the transaction
+	page, err := MockGetTransactionsByBank(bank.ID, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, page.FetchedRecordsCount, int32(1))
+	assert.NotNil(t, MockPurgeUser(user1.ID))
+
+	// The id of a purged user cannot be reused, since its balances and
+	// transactions stay on the ledger
+	Stub.SetCreator("Org1MSP", "admin,teller")
+	assert.NotNil(t, MockCreateUser(user1.ID, user1.Name, user1.Email))
+	_, err = MockBatchCreateUsers([]string{user1.ID}, map[string]smartcontract.PIIInput{
+		user1.ID: {Name: user1.Name, Email: user1.Email, Salt: testSalt},
+	})
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "was purged")
+	_, err = MockGetUserBalance(user1.ID, transaction1.Currency)
+	assert.NotNil(t, err)
+
+	// Nor can the hashes of its transactions, which the bank still lists
+	assert.Nil(t, MockCreateUser(user2.ID, user2.Name, user2.Email))
+	res := Stub.MockInvoke("uuid", [][]byte{[]byte("CreateTransaction"), []byte(user2.ID), []byte(transaction1.Hash),
+		[]byte(transaction1.Amount), []byte(transaction1.Currency), []byte(transaction1.Date), []byte(bank.ID)})
+	assert.NotEqual(t, res.Status, int32(shim.OK))
+	assert.Contains(t, res.Message, "belongs to the purged user 1 and its hash cannot be reused")
+	_, err = MockBatchCreateTransactions([]smartcontract.Transaction{
+		{UserId: user2.ID, Hash: transaction1.Hash, Amount: transaction1.Amount, Currency: transaction1.Currency, BankId: bank.ID},
+	})
+	assert.NotNil(t, err)
+	_, err = MockReverseTransaction(transaction1.Hash, "duplicate charge")
+	assert.NotNil(t, err)
+	page, err = MockGetTransactionsByBank(bank.ID, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, page.FetchedRecordsCount, int32(1))
+}
+
+func Test_GetUserHistory(t *testing.T) {
+	fmt.Println("Test_GetUserHistory-----------------")
+	NewStub()
+	err := MockCreateUser(user1.ID, user1.Name, user1.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	MockUpdateUser(user1.ID, "change name", "change.email@g.com")
+	MockPurgeUser(user1.ID)
+	MockCreateUser(user2.ID, user2.Name, user2.Email)
+
+	history, err := MockGetUserHistory(user1.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(history), 3)
+	assert.True(t, history[0].IsDelete)
+	assert.Nil(t, history[0].User)
+	assert.NotEqual(t, history[1].User.EmailHash, history[2].User.EmailHash)
+	assert.Equal(t, history[2].User.ID, user1.ID)
+	assert.Equal(t, history[2].TxID, "uuid")
+	assert.NotEqual(t, history[2].Timestamp, "")
+
+	_, err = MockGetUserHistory("3")
+	assert.NotNil(t, err)
 }
 
 func Test_GetAllUsers(t *testing.T) {
 	fmt.Println("MockGetAllUsers-----------------")
 	NewStub()
+	MockInitLedger()
 
 	MockCreateUser(user1.ID, user1.Name, user1.Email)
 	MockCreateUser(user2.ID, user2.Name, user2.Email)
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
 
-	users, err := MockGetAllUsers()
+	users, err := MockGetAllUsers(false)
 	if err != nil {
 		fmt.Println("GetAllUsers error", err)
 	}
 	fmt.Println("users: ", users)
-	// assert.Equal(t, len(users), 2)
+	assert.Equal(t, len(users), 2)
+}
+
+func Test_GetUsersPage(t *testing.T) {
+	fmt.Println("GetUsersPage-----------------")
+	NewStub()
+	MockInitLedger()
+
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateUser(user2.ID, user2.Name, user2.Email)
+	MockCreateUser("3", "Ken Wu", "ken.wu@g.com")
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+
+	page, err := MockGetUsersPage(2, "", false)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, page.FetchedRecordsCount, int32(2))
+	assert.Equal(t, page.Records[0].ID, user1.ID)
+	assert.Equal(t, page.Records[1].ID, user2.ID)
+	assert.NotEqual(t, page.Bookmark, "")
+
+	page, err = MockGetUsersPage(2, page.Bookmark, false)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, page.FetchedRecordsCount, int32(1))
+	assert.Equal(t, page.Records[0].ID, "3")
+	assert.Equal(t, page.Bookmark, "")
 }
 
 //新增 Test_CreateTransaction
+func Test_QueryUsers(t *testing.T) {
+	fmt.Println("Test_QueryUsers-----------------")
+	NewStub()
+	MockInitLedger()
+
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateUser(user2.ID, user2.Name, user2.Email)
+	MockCreateUser("3", "John Wu", "john.wu@g.com")
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	MockDeleteUser("3", "account closed")
+
+	page, err := MockQueryUsers(`{"id": {"$regex": "^[0-9]+$"}}`, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 2)
+	assert.Equal(t, page.Records[0].ID, user1.ID)
+	assert.Equal(t, page.Records[0].DocType, "user")
+
+	page, err = MockQueryUsers(`{"deactivated": true}`, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 1)
+	assert.Equal(t, page.Records[0].ID, "3")
+	for _, selector := range []string{
+		`{"$and": [{"deactivated": true}]}`,
+		`{"$or": [{"deactivated": true}, {"id": "1"}]}`,
+	} {
+		page, err = MockQueryUsers(selector, 10, "")
+		if err != nil {
+			t.FailNow()
+		}
+		assert.Equal(t, page.Records[len(page.Records)-1].ID, "3", selector)
+	}
+	page, err = MockQueryUsers(`{"$or": [{"id": "3"}, {"id": "1"}]}`, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 1)
+	assert.Equal(t, page.Records[0].ID, user1.ID)
+
+	page, err = MockQueryUsers(`{"id": {"$in": ["1", "2"]}}`, 1, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, page.FetchedRecordsCount, int32(1))
+	assert.Equal(t, page.Records[0].ID, user1.ID)
+	page, err = MockQueryUsers(`{"id": {"$in": ["1", "2"]}}`, 1, page.Bookmark)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, page.Records[0].ID, user2.ID)
+
+	// Personal data is not in the public records
+	page, err = MockQueryUsers(`{"name": "John Lee"}`, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 0)
+
+	_, err = MockQueryUsers(`["not", "a", "selector"]`, 10, "")
+	assert.NotNil(t, err)
+
+	user, err := MockGetUserByEmail(user2.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, user.ID, user2.ID)
+	assert.Equal(t, user.Name, user2.Name)
+	_, err = MockGetUserByEmail("john.wu@g.com")
+	assert.NotNil(t, err)
+
+	users, err := MockGetUsersByName(user1.Name)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(users), 1)
+	assert.Equal(t, users[0].Email, user1.Email)
+	users, err = MockGetUsersByName("John Wu")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(users), 0)
+}
+
+func Test_VerifyUserPII(t *testing.T) {
+	fmt.Println("Test_VerifyUserPII-----------------")
+	NewStub()
+	err := MockCreateUser(user1.ID, user1.Name, user1.Email)
+	if err != nil {
+		t.FailNow()
+	}
+
+	// Verification only needs the public record
+	Stub.SetCreator("Org2MSP", "")
+	verified, err := MockVerifyUserPII(user1.ID, user1.Name, user1.Email, testSalt)
+	assert.Nil(t, err)
+	assert.True(t, verified)
+	verified, err = MockVerifyUserPII(user1.ID, "", user1.Email, testSalt)
+	assert.Nil(t, err)
+	assert.True(t, verified)
+	verified, err = MockVerifyUserPII(user1.ID, user2.Name, user1.Email, testSalt)
+	assert.Nil(t, err)
+	assert.False(t, verified)
+	verified, err = MockVerifyUserPII(user1.ID, user1.Name, "", "another salt of 16+ chars")
+	assert.Nil(t, err)
+	assert.False(t, verified)
+	_, err = MockVerifyUserPII(user1.ID, "", "", testSalt)
+	assert.NotNil(t, err)
+
+	// The salt must be long enough and PII can only come through the transient map
+	Stub.SetCreator("Org1MSP", "admin")
+	res := Stub.MockInvokeWithTransient("uuid", [][]byte{[]byte("CreateUser"), []byte(user2.ID)},
+		map[string][]byte{"user": []byte(`{"name": "Amy Lin", "email": "amy.lin@g.com", "salt": "short"}`)})
+	assert.NotEqual(t, res.Status, int32(shim.OK))
+	res = Stub.MockInvoke("uuid", [][]byte{[]byte("CreateUser"), []byte(user2.ID)})
+	assert.NotEqual(t, res.Status, int32(shim.OK))
+
+	// Errors do not echo the personal data passed in the transient map
+	for _, input := range []string{
+		`{"name": "Amy Lin", "email": "amy.lin@g.com", "salt": "` + testSalt + `"`,
+		`{"name": "Amy Lin", "email": "amy.lin", "salt": "` + testSalt + `"}`,
+	} {
+		res = Stub.MockInvokeWithTransient("uuid", [][]byte{[]byte("CreateUser"), []byte(user2.ID)},
+			map[string][]byte{"user": []byte(input)})
+		assert.NotEqual(t, res.Status, int32(shim.OK))
+		assert.NotContains(t, res.Message, "amy.lin")
+		assert.NotContains(t, res.Message, testSalt)
+	}
+	for key, value := range Stub.State {
+		assert.NotContains(t, string(value), user1.Email, key)
+	}
+}
+
+func Test_QueryTransactions(t *testing.T) {
+	fmt.Println("Test_QueryTransactions-----------------")
+	NewStub()
+	MockInitLedger()
+
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateUser(user2.ID, user2.Name, user2.Email)
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	MockCreateTransaction(user2.ID, transaction2.Hash, transaction2.Amount, transaction2.Currency, transaction2.Date, bank.ID)
+
+	page, err := MockQueryTransactions(`{"currency": "TWD"}`, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 1)
+	assert.Equal(t, page.Records[0].UserId, user2.ID)
+
+	page, err = MockQueryTransactions(`{"bank_id": "04231910", "date": {"$gte": "2022-04-15T00:00:00Z"}}`, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 1)
+	assert.Equal(t, page.Records[0].Hash, transaction2.Hash)
+
+	page, err = MockQueryTransactions(`{"$or": [{"currency": "USD"}, {"currency": "TWD"}]}`, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 2)
+}
+
 func Test_CreateTransaction(t *testing.T) {
 	fmt.Println("CreateTransaction-----------------")
 	NewStub()
@@ -197,102 +588,1175 @@ func Test_CreateTransaction(t *testing.T) {
 	}
 	fmt.Println("CreateTransaction transaction2", result2)
 
-	user, err := MockGetUser(user1.ID)
+	page, err := MockGetUserTransactions(user1.ID, 10, "")
 	if err != nil {
-		fmt.Println("get User error", err)
+		fmt.Println("GetUserTransactions error", err)
 	}
-	fmt.Println(user)
-	assert.Equal(t, len(user.Transactions), 2)
-
+	fmt.Println(page)
+	assert.Equal(t, len(page.Records), 2)
+	assert.Equal(t, result1.Status, "created")
+	assert.Equal(t, result2.Transaction.Amount, "500.00")
 }
 
-func Test_GetUserByTransactionHash(t *testing.T) {
-	fmt.Println("GetUserByTransactionHash-----------------------")
+func Test_CreateTransactionIdempotent(t *testing.T) {
+	fmt.Println("CreateTransactionIdempotent-----------------")
 	NewStub()
 	MockInitLedger()
-	err := MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateUser(user2.ID, user2.Name, user2.Email)
+	result, err := MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
 	if err != nil {
 		t.FailNow()
 	}
-	result, err := MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	assert.Equal(t, result.Status, "created")
+
+	// The same payload, written differently, is a no-op
+	result, err = MockCreateTransaction(user1.ID, transaction1.Hash, "200.00", "usd", "2022-04-14T17:30:00+08:00", bank.ID)
 	if err != nil {
-		fmt.Println("CreateTransaction User", err)
+		t.FailNow()
 	}
-	fmt.Println("CreateTransaction transaction", result)
-
-	userJson, err := MockGetUserByTransactionHash(transaction1.Hash)
+	assert.Equal(t, result.Status, "duplicate")
+	assert.Equal(t, result.Transaction.Amount, "200.00")
+	assert.Nil(t, Stub.Event)
+	result, err = MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, "", bank.ID)
 	if err != nil {
-		fmt.Println("Get User By Transaction Hash error", err)
+		t.FailNow()
 	}
-	fmt.Println(userJson)
-	assert.Equal(t, userJson.ID, user1.ID)
-	assert.Equal(t, userJson.Name, user1.Name)
-	assert.Equal(t, userJson.Email, user1.Email)
-}
+	assert.Equal(t, result.Status, "duplicate")
 
-func Test_InitLedger(t *testing.T) {
-	fmt.Println("InitLedger-----------------")
-	NewStub()
-	var err = MockInitLedger()
-	assert.Equal(t, err, nil)
+	// Conflicting payloads are rejected
+	_, err = MockCreateTransaction(user2.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	assert.NotNil(t, err)
+	_, err = MockCreateTransaction(user1.ID, transaction1.Hash, "300", transaction1.Currency, transaction1.Date, bank.ID)
+	assert.NotNil(t, err)
+	_, err = MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, fubonBank.ID)
+	assert.NotNil(t, err)
+
+	page, err := MockGetUserTransactions(user1.ID, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 1)
+	bankJson, err := MockGetBankByID(bank.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, bankJson.TransactionCount, 1)
+	balance, err := MockGetUserBalance(user1.ID, "USD")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, balance.Balance, "200.00")
+	user, err := MockGetUserByTransactionHash(transaction1.Hash)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, user.ID, user1.ID)
 }
 
-func Test_GetBankByID(t *testing.T) {
-	fmt.Println("Test_GetBankByID-----------------")
+func Test_CreateTransactionValidation(t *testing.T) {
+	fmt.Println("CreateTransactionValidation-----------------")
 	NewStub()
 	MockInitLedger()
 	err := MockCreateUser(user1.ID, user1.Name, user1.Email)
 	if err != nil {
 		t.FailNow()
 	}
-	result, err := MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
-	fmt.Println("CreateTransaction transaction", result)
-	if err != nil {
-		fmt.Println("CreateTransaction User", err)
+
+	invalid := []struct {
+		amount   string
+		currency string
+		date     string
+		field    string
+	}{
+		{"abc", "USD", transaction1.Date, "amount"},
+		{"1.005", "USD", transaction1.Date, "amount"},
+		{"1.5", "JPY", transaction1.Date, "amount"},
+		{"0.00", "USD", transaction1.Date, "amount"},
+		{"99999999999999999999", "USD", transaction1.Date, "amount"},
+		{"200", "NTD", transaction1.Date, "currency"},
+		{"200", "USD", "2022-04-14", "date"},
+	}
+	for _, input := range invalid {
+		res := Stub.MockInvoke("uuid",
+			[][]byte{
+				[]byte("CreateTransaction"),
+				[]byte(user1.ID),
+				[]byte(transaction1.Hash),
+				[]byte(input.amount),
+				[]byte(input.currency),
+				[]byte(input.date),
+				[]byte(bank.ID),
+			})
+		assert.Equal(t, res.Status, int32(shim.ERROR))
+		var validationError smartcontract.ValidationError
+		err = json.Unmarshal([]byte(res.Message), &validationError)
+		assert.Nil(t, err)
+		assert.Equal(t, validationError.Field, input.field)
 	}
 
-	bankJson, err := MockGetBankByID(bank.ID)
+	_, err = MockCreateTransaction(user1.ID, transaction1.Hash, "-12.5", "usd", "", bank.ID)
 	if err != nil {
-		fmt.Println("get User error", err)
+		t.FailNow()
 	}
-	fmt.Println("bankJson: ", bankJson)
-	assert.Equal(t, bankJson.ID, bank.ID)
-	assert.Equal(t, bankJson.Name, bank.Name)
-	assert.Equal(t, bankJson.TransactionCount, 1)
+	_, err = MockCreateTransaction(user1.ID, transaction2.Hash, transaction2.Amount, transaction2.Currency, transaction2.Date, bank.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	page, err := MockGetUserTransactions(user1.ID, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, page.Records[0].Amount, "-12.50")
+	assert.Equal(t, page.Records[0].Currency, "USD")
+	assert.NotEqual(t, page.Records[0].Date, "")
+	assert.Equal(t, page.Records[1].Amount, "500.00")
+	assert.Equal(t, page.Records[1].Date, "2022-04-16T06:00:00Z")
 }
 
-func Test_CreateBank(t *testing.T) {
-	fmt.Println("Test_CreateBank-----------------")
+func Test_ReverseTransaction(t *testing.T) {
+	fmt.Println("ReverseTransaction-----------------")
 	NewStub()
+	MockInitLedger()
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateUser(user2.ID, user2.Name, user2.Email)
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	MockCreateTransaction(user1.ID, transaction2.Hash, transaction2.Amount, transaction2.Currency, transaction2.Date, bank.ID)
+
+	_, err := MockReverseTransaction(transaction1.Hash, "")
+	assert.NotNil(t, err)
+	_, err = MockReverseTransaction("0x000000009", "duplicate charge")
+	assert.NotNil(t, err)
 
-	err := MockCreateBank(testbank.ID, testbank.Name)
+	reversal, err := MockReverseTransaction(transaction1.Hash, "duplicate charge")
 	if err != nil {
 		t.FailNow()
 	}
-}
+	assert.Equal(t, reversal.Hash, transaction1.Hash+":reversal")
+	assert.Equal(t, reversal.Amount, "-200.00")
+	assert.Equal(t, reversal.ReversalOf, transaction1.Hash)
+	assert.Equal(t, reversal.Reason, "duplicate charge")
+	envelope := LastEvent(t, "TransactionReversed")
+	assert.Equal(t, len(envelope.Events), 4)
 
-//
-// 
-// Mock function
+	balance, _ := MockGetUserBalance(user1.ID, "USD")
+	assert.Equal(t, balance.Balance, "0.00")
+	bankJson, err := MockGetBankByID(bank.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, bankJson.TransactionCount, 2)
+	assert.Equal(t, bankJson.ReversalCount, 1)
+	page, err := MockGetUserTransactions(user1.ID, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 3)
+	for _, record := range page.Records {
+		if record.Hash == transaction1.Hash {
+			assert.Equal(t, record.ReversedBy, reversal.Hash)
+		}
+	}
 
-func MockUserExists(id string) (bool, error) {
-	res := Stub.MockInvoke("uuid", [][]byte{[]byte("UserExists"), []byte(id)})
-	if res.Status != shim.OK {
-		return false, errors.New("UserExists error")
+	// Neither the original nor the compensating entry can be reversed again
+	_, err = MockReverseTransaction(transaction1.Hash, "again")
+	assert.NotNil(t, err)
+	_, err = MockReverseTransaction(reversal.Hash, "again")
+	assert.NotNil(t, err)
+	// Retrying the original transaction still reports a duplicate
+	result, err := MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	if err != nil {
+		t.FailNow()
 	}
-	var result bool = false
-	json.Unmarshal(res.Payload, &result)
-	return result, nil
+	assert.Equal(t, result.Status, "duplicate")
+
+	// Transfer entries are reversed as a whole or not at all
+	MockTransferBetweenUsers("tx1", user1.ID, user2.ID, "100", "TWD", "")
+	_, err = MockReverseTransaction("tx1:debit", "mistake")
+	assert.NotNil(t, err)
+
+	// Reversing a credit is subject to overdraft protection
+	res := Stub.MockInvoke("uuid", [][]byte{[]byte("SetOverdraftProtection"), []byte("true")})
+	assert.Equal(t, res.Status, int32(shim.OK))
+	_, err = MockReverseTransaction(transaction2.Hash, "wrong amount")
+	assert.NotNil(t, err)
+	balance, _ = MockGetUserBalance(user1.ID, "TWD")
+	assert.Equal(t, balance.Balance, "400.00")
 }
 
-func MockCreateUser(id string, name string, email string) error {
-	res := Stub.MockInvoke("uuid",
+func Test_ReservedTransactionHashes(t *testing.T) {
+	fmt.Println("ReservedTransactionHashes-----------------")
+	NewStub()
+	MockInitLedger()
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+
+	// Clients cannot take the hashes of reversal and transfer entries
+	for _, hash := range []string{"0xdef:reversal", "0xdef:debit", "0xdef:credit"} {
+		res := Stub.MockInvoke("uuid",
+			[][]byte{
+				[]byte("CreateTransaction"),
+				[]byte(user1.ID),
+				[]byte(hash),
+				[]byte("100"),
+				[]byte("USD"),
+				[]byte(""),
+				[]byte(bank.ID),
+			})
+		assert.Equal(t, res.Status, int32(shim.ERROR))
+		var validationError smartcontract.ValidationError
+		err := json.Unmarshal([]byte(res.Message), &validationError)
+		assert.Nil(t, err)
+		assert.Equal(t, validationError.Field, "hash")
+	}
+	_, err := MockBatchCreateTransactions([]smartcontract.Transaction{
+		{UserId: user1.ID, Hash: "0xdef", Amount: "100", Currency: "USD", BankId: bank.ID},
+		{UserId: user1.ID, Hash: "0xdef:reversal", Amount: "-100", Currency: "USD", BankId: bank.ID},
+	})
+	batchErr, ok := err.(*smartcontract.BatchError)
+	if !ok {
+		t.FailNow()
+	}
+	assert.Equal(t, len(batchErr.Items), 1)
+	assert.Equal(t, batchErr.Items[0].Index, 1)
+
+	_, err = MockCreateTransaction(user1.ID, "0xdef", "100", "USD", "", bank.ID)
+	assert.Nil(t, err)
+	reversal, err := MockReverseTransaction("0xdef", "duplicate charge")
+	assert.Nil(t, err)
+	assert.Equal(t, reversal.Hash, "0xdef:reversal")
+}
+
+func Test_UserBalances(t *testing.T) {
+	fmt.Println("UserBalances-----------------")
+	NewStub()
+	MockInitLedger()
+	err := MockCreateUser(user1.ID, user1.Name, user1.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	MockCreateTransaction(user1.ID, transaction2.Hash, transaction2.Amount, transaction2.Currency, transaction2.Date, bank.ID)
+	MockCreateTransaction(user1.ID, "0x000000003", "-250.25", "USD", "", bank.ID)
+
+	balance, err := MockGetUserBalance(user1.ID, "USD")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, balance.Balance, "-50.25")
+
+	balance, err = MockGetUserBalance(user1.ID, "EUR")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, balance.Balance, "0.00")
+
+	balances, err := MockGetUserBalances(user1.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(balances), 2)
+	assert.Equal(t, balances[0].Currency, "TWD")
+	assert.Equal(t, balances[0].Balance, "500.00")
+	assert.Equal(t, balances[1].Currency, "USD")
+}
+
+func Test_FxRates(t *testing.T) {
+	fmt.Println("FxRates-----------------")
+	NewStub()
+	MockInitLedger()
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	MockCreateTransaction(user1.ID, transaction2.Hash, transaction2.Amount, transaction2.Currency, transaction2.Date, bank.ID)
+
+	assert.NotNil(t, MockSetFxRate("USD", "TWD", "-1", "2022-04-01"))
+	assert.NotNil(t, MockSetFxRate("USD", "TWD", "0", "2022-04-01"))
+	assert.NotNil(t, MockSetFxRate("USD", "TWD", "1/3", "2022-04-01"))
+	assert.NotNil(t, MockSetFxRate("USD", "USD", "1", "2022-04-01"))
+	assert.NotNil(t, MockSetFxRate("USD", "NTD", "29", "2022-04-01"))
+	assert.NotNil(t, MockSetFxRate("USD", "TWD", "29", "2022/04/01"))
+	assert.Nil(t, MockSetFxRate("usd", "twd", "29.0", "2022-04-01"))
+	assert.Nil(t, MockSetFxRate("USD", "TWD", "30.5", "2022-04-15"))
+	envelope := LastEvent(t, "FxRateSet")
+	assert.Equal(t, envelope.Events[0].EntityID, "USD/TWD/2022-04-15")
+
+	rate, err := MockGetFxRate("USD", "TWD", "2022-04-14")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, rate.Rate, "29.0")
+	assert.Equal(t, rate.EffectiveDate, "2022-04-01")
+	rate, err = MockGetFxRate("USD", "TWD", "2022-04-15T00:00:00Z")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, rate.Rate, "30.5")
+	rate, err = MockGetFxRate("TWD", "USD", "2022-04-01")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, rate.Rate, "0.0344827586")
+	_, err = MockGetFxRate("USD", "TWD", "2022-03-31")
+	assert.NotNil(t, err)
+
+	balance, err := MockGetUserBalanceIn(user1.ID, "TWD")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, balance.Balance, "6300.00")
+	balance, err = MockGetUserBalanceIn(user1.ID, "USD")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, balance.Balance, "216.39")
+	_, err = MockGetUserBalanceIn(user1.ID, "EUR")
+	assert.NotNil(t, err)
+	_, err = MockGetUserBalanceIn(user2.ID, "USD")
+	assert.NotNil(t, err)
+
+	// A later rate of the opposite pair supersedes the rate of the pair
+	assert.Nil(t, MockSetFxRate("TWD", "USD", "0.04", "2023-01-01"))
+	rate, err = MockGetFxRate("USD", "TWD", "2023-06-01")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, rate.Rate, "25.0000000000")
+	assert.Equal(t, rate.EffectiveDate, "2023-01-01")
+	rate, err = MockGetFxRate("USD", "TWD", "2022-12-31")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, rate.Rate, "30.5")
+	assert.Nil(t, MockSetFxRate("USD", "TWD", "31", "2023-01-01"))
+	rate, err = MockGetFxRate("USD", "TWD", "2023-06-01")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, rate.Rate, "31")
+}
+
+func Test_OverdraftProtection(t *testing.T) {
+	fmt.Println("OverdraftProtection-----------------")
+	NewStub()
+	MockInitLedger()
+	err := MockCreateUser(user1.ID, user1.Name, user1.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+
+	res := Stub.MockInvoke("uuid", [][]byte{[]byte("SetOverdraftProtection"), []byte("true")})
+	assert.Equal(t, res.Status, int32(shim.OK))
+
+	_, err = MockCreateTransaction(user1.ID, "0x000000003", "-200.01", "USD", "", bank.ID)
+	assert.NotNil(t, err)
+	_, err = MockCreateTransaction(user1.ID, "0x000000004", "-200", "USD", "", bank.ID)
+	assert.Nil(t, err)
+
+	balance, err := MockGetUserBalance(user1.ID, "USD")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, balance.Balance, "0.00")
+	page, err := MockGetUserTransactions(user1.ID, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 2)
+}
+
+func Test_BatchCreateUsers(t *testing.T) {
+	fmt.Println("BatchCreateUsers-----------------")
+	NewStub()
+	MockInitLedger()
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+
+	users := map[string]smartcontract.PIIInput{
+		"3": {Name: "Ken Wu", Email: "ken.wu@g.com", Salt: testSalt},
+		"4": {Name: "Ada Chen", Email: "ADA.chen@g.com", Salt: testSalt},
+		"5": {Name: "Ada Chen", Email: "ada.chen@g.com", Salt: testSalt},
+		"6": {Name: "Ian Ho", Email: user1.Email, Salt: testSalt},
+		"7": {Name: "Ian Ho", Email: "ian.ho", Salt: testSalt},
+		"8": {Name: "Ian Ho", Email: "ian.ho@g.com", Salt: "short"},
+	}
+	_, err := MockBatchCreateUsers([]string{"3", "4", "5", "6", "7", "8", "9", "3", user1.ID}, users)
+	batchErr, ok := err.(*smartcontract.BatchError)
+	if !ok {
+		t.FailNow()
+	}
+	indexes := []int{}
+	for _, item := range batchErr.Items {
+		indexes = append(indexes, item.Index)
+	}
+	assert.Equal(t, indexes, []int{2, 3, 4, 5, 6, 7, 8})
+	exists, _ := MockUserExists("3")
+	assert.False(t, exists)
+
+	_, err = MockBatchCreateUsers([]string{}, users)
+	assert.NotNil(t, err)
+	ids := []string{}
+	for i := 0; i <= 500; i++ {
+		ids = append(ids, fmt.Sprint(100+i))
+	}
+	_, err = MockBatchCreateUsers(ids, users)
+	assert.NotNil(t, err)
+
+	result, err := MockBatchCreateUsers([]string{"3", "4"}, users)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(result.Items), 2)
+	assert.Equal(t, result.Items[1].ID, "4")
+	assert.Equal(t, result.Items[1].Status, "created")
+	envelope := LastEvent(t, "UserCreated")
+	assert.Equal(t, len(envelope.Events), 2)
+	pii, err := MockGetUserByEmail("ada.chen@g.com")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, pii.ID, "4")
+}
+
+func Test_BatchCreateTransactions(t *testing.T) {
+	fmt.Println("BatchCreateTransactions-----------------")
+	NewStub()
+	MockInitLedger()
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateUser(user2.ID, user2.Name, user2.Email)
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	res := Stub.MockInvoke("uuid", [][]byte{[]byte("SetOverdraftProtection"), []byte("true")})
+	assert.Equal(t, res.Status, int32(shim.OK))
+
+	transactions := []smartcontract.Transaction{
+		{UserId: user1.ID, Hash: "0x000000011", Amount: "-150", Currency: "USD", BankId: bank.ID},
+		{UserId: user1.ID, Hash: "0x000000012", Amount: "-60", Currency: "USD", BankId: bank.ID},
+		{UserId: user2.ID, Hash: "0x000000013", Amount: "10", Currency: "TWD", BankId: "99999999"},
+		{UserId: "3", Hash: "0x000000014", Amount: "10", Currency: "TWD", BankId: bank.ID},
+		{UserId: user2.ID, Hash: "0x000000011", Amount: "10", Currency: "TWD", BankId: bank.ID},
+		{UserId: user2.ID, Hash: transaction1.Hash, Amount: "10", Currency: "TWD", BankId: bank.ID},
+		{UserId: user2.ID, Hash: "0x000000015", Amount: "10", Currency: "XYZ", BankId: bank.ID},
+	}
+	_, err := MockBatchCreateTransactions(transactions)
+	batchErr, ok := err.(*smartcontract.BatchError)
+	if !ok {
+		t.FailNow()
+	}
+	indexes := []int{}
+	for _, item := range batchErr.Items {
+		indexes = append(indexes, item.Index)
+	}
+	assert.Equal(t, indexes, []int{1, 2, 3, 4, 5, 6})
+	balance, _ := MockGetUserBalance(user1.ID, "USD")
+	assert.Equal(t, balance.Balance, "200.00")
+
+	transactions = []smartcontract.Transaction{
+		{UserId: user1.ID, Hash: transaction1.Hash, Amount: transaction1.Amount, Currency: transaction1.Currency, Date: transaction1.Date, BankId: bank.ID},
+		{UserId: user1.ID, Hash: "0x000000011", Amount: "-150", Currency: "USD", BankId: bank.ID},
+		{UserId: user1.ID, Hash: "0x000000012", Amount: "-50", Currency: "usd", BankId: fubonBank.ID},
+		{UserId: user2.ID, Hash: "0x000000013", Amount: "10", Currency: "TWD", BankId: bank.ID},
+		{UserId: user1.ID, Hash: "0x000000014", Amount: "30", Currency: "USD", BankId: bank.ID},
+	}
+	result, err := MockBatchCreateTransactions(transactions)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(result.Items), 5)
+	assert.Equal(t, result.Items[0].Status, "duplicate")
+	assert.Equal(t, result.Items[1].Status, "created")
+	envelope := LastEvent(t, "TransactionCreated")
+	assert.Equal(t, len(envelope.Events), 4+2+2)
+
+	balance, _ = MockGetUserBalance(user1.ID, "USD")
+	assert.Equal(t, balance.Balance, "30.00")
+	balance, _ = MockGetUserBalance(user2.ID, "TWD")
+	assert.Equal(t, balance.Balance, "10.00")
+	bankJson, _ := MockGetBankByID(bank.ID)
+	assert.Equal(t, bankJson.TransactionCount, 4)
+	bankJson, _ = MockGetBankByID(fubonBank.ID)
+	assert.Equal(t, bankJson.TransactionCount, 1)
+	page, err := MockGetUserTransactions(user1.ID, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 4)
+}
+
+func Test_TransferBetweenUsers(t *testing.T) {
+	fmt.Println("TransferBetweenUsers-----------------")
+	NewStub()
+	MockInitLedger()
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateUser(user2.ID, user2.Name, user2.Email)
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+
+	transfer, err := MockTransferBetweenUsers("tx1", user1.ID, user2.ID, "75.5", "usd", "rent")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, transfer.ID, "tx1")
+	assert.Equal(t, transfer.Amount, "75.50")
+	assert.Equal(t, transfer.Currency, "USD")
+	assert.Equal(t, transfer.Reference, "rent")
+	envelope := LastEvent(t, "TransferCreated")
+	assert.Equal(t, envelope.Function, "TransferBetweenUsers")
+	assert.Equal(t, len(envelope.Events), 5)
+
+	balance, _ := MockGetUserBalance(user1.ID, "USD")
+	assert.Equal(t, balance.Balance, "124.50")
+	balance, _ = MockGetUserBalance(user2.ID, "USD")
+	assert.Equal(t, balance.Balance, "75.50")
+	page, err := MockGetUserTransactions(user2.ID, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 1)
+	assert.Equal(t, page.Records[0].Hash, "tx1:credit")
+	assert.Equal(t, page.Records[0].TransferId, "tx1")
+	owner, err := MockGetUserByTransactionHash("tx1:debit")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, owner.ID, user1.ID)
+
+	res := Stub.MockInvoke("uuid", [][]byte{[]byte("GetTransfer"), []byte("tx1")})
+	assert.Equal(t, res.Status, int32(shim.OK))
+
+	// Invalid transfers change nothing
+	_, err = MockTransferBetweenUsers("tx2", user1.ID, user1.ID, "1", "USD", "")
+	assert.NotNil(t, err)
+	_, err = MockTransferBetweenUsers("tx3", user1.ID, "3", "1", "USD", "")
+	assert.NotNil(t, err)
+	_, err = MockTransferBetweenUsers("tx4", user1.ID, user2.ID, "-1", "USD", "")
+	assert.NotNil(t, err)
+	_, err = MockTransferBetweenUsers("tx5", user1.ID, user2.ID, "0.001", "USD", "")
+	assert.NotNil(t, err)
+	MockDeleteUser(user2.ID, "closed")
+	_, err = MockTransferBetweenUsers("tx6", user1.ID, user2.ID, "1", "USD", "")
+	assert.NotNil(t, err)
+	MockRestoreUser(user2.ID)
+
+	res = Stub.MockInvoke("uuid", [][]byte{[]byte("SetOverdraftProtection"), []byte("true")})
+	assert.Equal(t, res.Status, int32(shim.OK))
+	_, err = MockTransferBetweenUsers("tx7", user1.ID, user2.ID, "124.51", "USD", "")
+	assert.NotNil(t, err)
+
+	balance, _ = MockGetUserBalance(user1.ID, "USD")
+	assert.Equal(t, balance.Balance, "124.50")
+	balance, _ = MockGetUserBalance(user2.ID, "USD")
+	assert.Equal(t, balance.Balance, "75.50")
+	res = Stub.MockInvoke("uuid", [][]byte{[]byte("GetTransfer"), []byte("tx7")})
+	assert.NotEqual(t, res.Status, int32(shim.OK))
+}
+
+func Test_GetTransactionsByBank(t *testing.T) {
+	fmt.Println("GetTransactionsByBank-----------------")
+	NewStub()
+	MockInitLedger()
+	MockCreateBank(testbank)
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateUser(user2.ID, user2.Name, user2.Email)
+
+	_, err := MockCreateTransaction(user1.ID, "0x000000009", transaction1.Amount, transaction1.Currency, transaction1.Date, "99999999")
+	assert.NotNil(t, err)
+	page, err := MockGetUserTransactions(user1.ID, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 0)
+
+	MockCreateTransaction(user2.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	MockCreateTransaction(user1.ID, transaction2.Hash, transaction2.Amount, transaction2.Currency, transaction2.Date, bank.ID)
+	MockCreateTransaction(user1.ID, "0x000000003", "10", "USD", "", testbank.ID)
+
+	page, err = MockGetTransactionsByBank(bank.ID, 1, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 1)
+	assert.Equal(t, page.Records[0].UserId, user1.ID)
+	assert.Equal(t, page.Records[0].BankId, bank.ID)
+
+	page, err = MockGetTransactionsByBank(bank.ID, 1, page.Bookmark)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 1)
+	assert.Equal(t, page.Records[0].UserId, user2.ID)
+	assert.Equal(t, page.Bookmark, "")
+
+	page, err = MockGetTransactionsByBank(testbank.ID, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 1)
+	assert.Equal(t, page.Records[0].Hash, "0x000000003")
+
+	_, err = MockGetTransactionsByBank("99999999", 10, "")
+	assert.NotNil(t, err)
+}
+
+func Test_GetUserTransactions(t *testing.T) {
+	fmt.Println("GetUserTransactions-----------------")
+	NewStub()
+	MockInitLedger()
+	err := MockCreateUser(user1.ID, user1.Name, user1.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	MockCreateTransaction(user1.ID, transaction2.Hash, transaction2.Amount, transaction2.Currency, transaction2.Date, bank.ID)
+
+	page, err := MockGetUserTransactions(user1.ID, 1, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, page.FetchedRecordsCount, int32(1))
+	assert.Equal(t, page.Records[0].Hash, transaction1.Hash)
+	assert.Equal(t, page.Records[0].UserId, user1.ID)
+	assert.NotEqual(t, page.Bookmark, "")
+
+	page, err = MockGetUserTransactions(user1.ID, 1, page.Bookmark)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, page.FetchedRecordsCount, int32(1))
+	assert.Equal(t, page.Records[0].Hash, transaction2.Hash)
+	assert.Equal(t, page.Bookmark, "")
+
+	user, err := MockGetUser(user1.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, user.ID, user1.ID)
+}
+
+func Test_MigrateKeys(t *testing.T) {
+	fmt.Println("MigrateKeys-----------------")
+	NewStub()
+	legacyJson := `{"id":"1","name":"John Lee","email":"john.lee@g.com","transactions":[` +
+		`{"hash":"0x000000001","amount":"200","currency":"USD","date":"2022-04-14"},` +
+		`{"hash":"0x000000002","amount":"500","currency":"NTD","date":"2022-04-16"}]}`
+	Stub.MockTransactionStart("legacy")
+	Stub.PutState(user1.ID, []byte(legacyJson))
+	Stub.PutState(user2.ID, []byte(`{"id":"2","name":"Amy Lin","email":"amy.lin@g.com"}`))
+	Stub.PutState(transaction1.Hash, []byte(`{"user_id":"1"}`))
+	Stub.PutState("Bank_04231910", []byte(`{"id":"04231910","name":"國泰世華商業銀行","transaction_count":1}`))
+	Stub.MockTransactionEnd("legacy")
+
+	result, err := MockMigrateKeys()
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, result.Users, 2)
+	assert.Equal(t, result.Transactions, 2)
+	assert.Equal(t, result.TransactionHashes, 1)
+	assert.Equal(t, result.Banks, 1)
+	assert.Equal(t, result.UsersPII, 2)
+	// NTD is not an ISO 4217 code, so the second transaction is left out of the balances
+	assert.Equal(t, result.Balances, 1)
+	assert.Equal(t, result.UnbalancedTransactions, 1)
+
+	for _, legacyKey := range []string{user1.ID, user2.ID, transaction1.Hash, "Bank_04231910"} {
+		assert.Nil(t, Stub.State[legacyKey])
+	}
+
+	page, err := MockGetUserTransactions(user1.ID, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 2)
+	// Entries are stored as CreateTransaction stores them, except invalid amounts and currencies
+	assert.Equal(t, page.Records[0].Amount, "200.00")
+	assert.Equal(t, page.Records[0].Date, "2022-04-14T00:00:00Z")
+	assert.Equal(t, page.Records[1].Amount, "500")
+	assert.Equal(t, page.Records[1].Date, "2022-04-16T00:00:00Z")
+	balance, err := MockGetUserBalance(user1.ID, "USD")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, balance.Balance, "200.00")
+
+	// Migrated histories convert, leaving out the entries that cannot be parsed
+	err = MockSetFxRate("USD", "TWD", "30", "2022-01-01")
+	if err != nil {
+		t.FailNow()
+	}
+	balance, err = MockGetUserBalanceIn(user1.ID, "TWD")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, balance.Balance, "6000.00")
+	assert.Equal(t, balance.SkippedTransactions, []string{"0x000000002"})
+
+	pii, err := MockGetUserPII(user2.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, pii.Email, user2.Email)
+	pii, err = MockGetUserByEmail(user1.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, pii.Name, user1.Name)
+
+	user, err := MockGetUserByTransactionHash(transaction1.Hash)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, user.ID, user1.ID)
+
+	bankJson, err := MockGetBankByID(bank.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, bankJson.TransactionCount, 1)
+
+	result, err = MockMigrateKeys()
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, *result, smartcontract.MigrationResult{})
+}
+
+func Test_MigrateKeysDuplicateEmail(t *testing.T) {
+	fmt.Println("MigrateKeysDuplicateEmail-----------------")
+	NewStub()
+	Stub.MockTransactionStart("legacy")
+	Stub.PutState("1", []byte(`{"id":"1","name":"John Lee","email":"john.lee@g.com"}`))
+	Stub.PutState("2", []byte(`{"id":"2","name":"Johnny Lee","email":"John.Lee@g.com"}`))
+	Stub.MockTransactionEnd("legacy")
+
+	// The peer does not read back the email reserved for the first user in the
+	// same transaction, so the migration must catch the duplicate itself
+	res := Stub.MockInvokeWithTransient("uuid", [][]byte{[]byte("MigrateKeys")},
+		map[string][]byte{"salt": []byte(testSalt)})
+	assert.NotEqual(t, res.Status, int32(shim.OK))
+	assert.Contains(t, res.Message, "is used by both user 1 and user 2")
+}
+
+func Test_ReverseMigratedTransaction(t *testing.T) {
+	fmt.Println("ReverseMigratedTransaction-----------------")
+	NewStub()
+	MockInitLedger()
+	Stub.MockTransactionStart("legacy")
+	Stub.PutState(user1.ID, []byte(`{"id":"1","name":"John Lee","email":"john.lee@g.com","transactions":[`+
+		`{"hash":"0x000000001","amount":"200","currency":"USD","date":"2022-04-14"},`+
+		`{"hash":"0x000000002","amount":"500","currency":"NTD","date":"2022-04-16"}]}`))
+	Stub.PutState("0x000000001", []byte(`{"user_id":"1"}`))
+	Stub.PutState("0x000000002", []byte(`{"user_id":"1"}`))
+	Stub.MockTransactionEnd("legacy")
+	_, err := MockMigrateKeys()
+	if err != nil {
+		t.FailNow()
+	}
+
+	// Legacy entries have no bank, so no bank counts the reversal
+	reversal, err := MockReverseTransaction("0x000000001", "duplicate charge")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, reversal.Amount, "-200.00")
+	assert.Equal(t, reversal.BankId, "")
+	envelope := LastEvent(t, "TransactionReversed")
+	assert.Equal(t, len(envelope.Events), 3)
+	balance, err := MockGetUserBalance(user1.ID, "USD")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, balance.Balance, "0.00")
+	bankJson, err := MockGetBankByID(bank.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, bankJson.ReversalCount, 0)
+
+	res := Stub.MockInvoke("uuid", [][]byte{[]byte("ReverseTransaction"), []byte("0x000000002"), []byte("duplicate charge")})
+	assert.NotEqual(t, res.Status, int32(shim.OK))
+	assert.Contains(t, res.Message, `the transaction 0x000000002 has the invalid currency "NTD" and cannot be reversed`)
+}
+
+func Test_KeyNamespaces(t *testing.T) {
+	fmt.Println("KeyNamespaces-----------------")
+	NewStub()
+	MockInitLedger()
+
+	err := MockCreateUser("Bank_"+bank.ID, user1.Name, "bank.user@g.com")
+	if err != nil {
+		t.FailNow()
+	}
+	err = MockCreateUser(transaction1.Hash, user2.Name, user2.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	err = MockCreateUser(user1.ID, user1.Name, user1.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	_, err = MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	if err != nil {
+		t.FailNow()
+	}
+
+	bankJson, err := MockGetBankByID(bank.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, bankJson.Name, bank.Name)
+
+	pii, err := MockGetUserPII(transaction1.Hash)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, pii.Name, user2.Name)
+
+	user, err := MockGetUserByTransactionHash(transaction1.Hash)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, user.ID, user1.ID)
+}
+
+func Test_GetUserByTransactionHash(t *testing.T) {
+	fmt.Println("GetUserByTransactionHash-----------------------")
+	NewStub()
+	MockInitLedger()
+	err := MockCreateUser(user1.ID, user1.Name, user1.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	result, err := MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	if err != nil {
+		fmt.Println("CreateTransaction User", err)
+	}
+	fmt.Println("CreateTransaction transaction", result)
+
+	userJson, err := MockGetUserByTransactionHash(transaction1.Hash)
+	if err != nil {
+		fmt.Println("Get User By Transaction Hash error", err)
+	}
+	fmt.Println(userJson)
+	assert.Equal(t, userJson.ID, user1.ID)
+}
+
+func Test_InitLedger(t *testing.T) {
+	fmt.Println("InitLedger-----------------")
+	NewStub()
+	var err = MockInitLedger()
+	assert.Equal(t, err, nil)
+	envelope := LastEvent(t, "BankCreated")
+	assert.Equal(t, len(envelope.Events), 2)
+
+	banks, err := MockGetAllBanks()
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(banks), 2)
+	assert.Equal(t, banks[0].ID, fubonBank.ID)
+	assert.Equal(t, banks[0].SwiftCode, fubonBank.SwiftCode)
+	assert.Equal(t, banks[1].Status, "active")
+
+	res := Stub.MockInvoke("uuid", [][]byte{[]byte("InitLedger"), []byte(`[{"id": "654321", "name": "Test bank", "swift_code": "TESTTWTP", "country": "TW"}]`)})
+	assert.Equal(t, res.Status, int32(shim.OK))
+	res = Stub.MockInvoke("uuid", [][]byte{[]byte("InitLedger"), []byte(`{"id": "654321"}`)})
+	assert.NotEqual(t, res.Status, int32(shim.OK))
+	assert.NotNil(t, MockInitLedger())
+}
+
+func Test_GetBankByID(t *testing.T) {
+	fmt.Println("Test_GetBankByID-----------------")
+	NewStub()
+	MockInitLedger()
+	err := MockCreateUser(user1.ID, user1.Name, user1.Email)
+	if err != nil {
+		t.FailNow()
+	}
+	result, err := MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	fmt.Println("CreateTransaction transaction", result)
+	if err != nil {
+		fmt.Println("CreateTransaction User", err)
+	}
+
+	bankJson, err := MockGetBankByID(bank.ID)
+	if err != nil {
+		fmt.Println("get User error", err)
+	}
+	fmt.Println("bankJson: ", bankJson)
+	assert.Equal(t, bankJson.ID, bank.ID)
+	assert.Equal(t, bankJson.Name, bank.Name)
+	assert.Equal(t, bankJson.TransactionCount, 1)
+}
+
+func Test_GetBankStats(t *testing.T) {
+	fmt.Println("GetBankStats-----------------")
+	NewStub()
+	MockInitLedger()
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	MockCreateTransaction(user1.ID, transaction2.Hash, transaction2.Amount, transaction2.Currency, transaction2.Date, bank.ID)
+	MockCreateTransaction(user1.ID, "0x000000003", "-75.25", "USD", "2022-04-14T23:00:00Z", bank.ID)
+	MockCreateTransaction(user1.ID, "0x000000004", "-50", "USD", "2022-04-14T20:00:00-05:00", bank.ID)
+	MockCreateTransaction(user1.ID, "0x000000005", "10", "USD", "2022-04-14T10:00:00Z", fubonBank.ID)
+	_, err := MockBatchCreateTransactions([]smartcontract.Transaction{
+		{UserId: user1.ID, Hash: "0x000000006", Amount: "100", Currency: "TWD", Date: "2022-04-16T12:00:00Z", BankId: bank.ID},
+		{UserId: user1.ID, Hash: "0x000000007", Amount: "-700", Currency: "TWD", Date: "2022-04-16T13:00:00Z", BankId: bank.ID},
+	})
+	if err != nil {
+		t.FailNow()
+	}
+
+	stats, err := MockGetBankStats(bank.ID, "2022-04-14", "2022-04-15")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(stats), 2)
+	assert.Equal(t, *stats[0], smartcontract.BankDailyStats{BankId: bank.ID, Date: "2022-04-14", Currency: "USD", Count: 2, Volume: "275.25", Min: "-75.25", Max: "200.00"})
+	assert.Equal(t, *stats[1], smartcontract.BankDailyStats{BankId: bank.ID, Date: "2022-04-15", Currency: "USD", Count: 1, Volume: "50.00", Min: "-50.00", Max: "-50.00"})
+	stats, err = MockGetBankStats(bank.ID, "2022-04-16", "2022-04-16")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(stats), 1)
+	assert.Equal(t, *stats[0], smartcontract.BankDailyStats{BankId: bank.ID, Date: "2022-04-16", Currency: "TWD", Count: 3, Volume: "1300.00", Min: "-700.00", Max: "500.00"})
+	stats, err = MockGetBankStats(fubonBank.ID, "2022-01-01", "2022-12-31")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(stats), 1)
+
+	// Reversals are counted on the day they are made
+	_, err = MockReverseTransaction(transaction1.Hash, "duplicate charge")
+	if err != nil {
+		t.FailNow()
+	}
+	stats, _ = MockGetBankStats(bank.ID, "2022-04-14", "2022-04-14")
+	assert.Equal(t, stats[0].Count, 2)
+	stats, _ = MockGetBankStats(bank.ID, "2022-04-17", "2999-12-31")
+	assert.Equal(t, len(stats), 1)
+	assert.Equal(t, stats[0].Min, "-200.00")
+
+	_, err = MockGetBankStats(bank.ID, "2022-04-15", "2022-04-14")
+	assert.NotNil(t, err)
+	_, err = MockGetBankStats(bank.ID, "2022/04/14", "2022-04-15")
+	assert.NotNil(t, err)
+	_, err = MockGetBankStats("99999999", "2022-04-14", "2022-04-15")
+	assert.NotNil(t, err)
+}
+
+func Test_CreateBank(t *testing.T) {
+	fmt.Println("Test_CreateBank-----------------")
+	NewStub()
+
+	err := MockCreateBank(testbank)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.NotNil(t, MockCreateBank(testbank))
+	assert.NotNil(t, MockCreateBank(smartcontract.Bank{ID: "654321", Name: "Test bank", SwiftCode: "TESTTW", Country: "TW"}))
+	assert.NotNil(t, MockCreateBank(smartcontract.Bank{ID: "654321", Name: "Test bank", SwiftCode: "TESTJPJT", Country: "TW"}))
+	assert.NotNil(t, MockCreateBank(smartcontract.Bank{ID: "654321", Name: "Test bank", SwiftCode: "TESTTWTP", Country: "Taiwan"}))
+	assert.NotNil(t, MockCreateBank(smartcontract.Bank{ID: "654321", Name: "", SwiftCode: "TESTTWTP", Country: "TW"}))
+}
+
+func Test_UpdateBank(t *testing.T) {
+	fmt.Println("Test_UpdateBank-----------------")
+	NewStub()
+	MockInitLedger()
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+
+	err := MockUpdateBank(bank.ID, "Cathay United Bank", "UWCBTWTP100", "TW")
+	if err != nil {
+		t.FailNow()
+	}
+	envelope := LastEvent(t, "BankUpdated")
+	assert.Equal(t, envelope.Events[0].EntityID, bank.ID)
+
+	bankJson, err := MockGetBankByID(bank.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, bankJson.Name, "Cathay United Bank")
+	assert.Equal(t, bankJson.SwiftCode, "UWCBTWTP100")
+	assert.Equal(t, bankJson.TransactionCount, 1)
+	assert.Equal(t, bankJson.Status, "active")
+
+	assert.NotNil(t, MockUpdateBank(bank.ID, "Cathay United Bank", "UWCBTWTP", "JP"))
+	assert.NotNil(t, MockUpdateBank("99999999", "Cathay United Bank", "UWCBTWTP", "TW"))
+	Stub.SetCreator("Org2MSP", "teller")
+	assert.NotNil(t, MockUpdateBank(bank.ID, bank.Name, bank.SwiftCode, bank.Country))
+}
+
+func Test_DeactivateBank(t *testing.T) {
+	fmt.Println("Test_DeactivateBank-----------------")
+	NewStub()
+	MockInitLedger()
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+
+	err := MockDeactivateBank(bank.ID)
+	if err != nil {
+		t.FailNow()
+	}
+	envelope := LastEvent(t, "BankDeactivated")
+	assert.Equal(t, envelope.Events[0].Data.(map[string]interface{})["status"], "inactive")
+	assert.NotNil(t, MockDeactivateBank(bank.ID))
+
+	_, err = MockCreateTransaction(user1.ID, transaction2.Hash, transaction2.Amount, transaction2.Currency, transaction2.Date, bank.ID)
+	assert.NotNil(t, err)
+	_, err = MockCreateTransaction(user1.ID, transaction2.Hash, transaction2.Amount, transaction2.Currency, transaction2.Date, fubonBank.ID)
+	assert.Nil(t, err)
+
+	page, err := MockGetTransactionsByBank(bank.ID, 10, "")
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(page.Records), 1)
+	banks, err := MockGetAllBanks()
+	if err != nil {
+		t.FailNow()
+	}
+	assert.Equal(t, len(banks), 2)
+}
+
+func Test_AccessControl(t *testing.T) {
+	fmt.Println("Test_AccessControl-----------------")
+	NewStub()
+	Stub.SetCreator("Org1MSP", "")
+	err := MockInitLedger()
+	if err != nil {
+		t.FailNow()
+	}
+
+	// Certificate roles only count for the MSPs trusted with them
+	Stub.SetCreator("Org9MSP", "admin")
+	assert.NotNil(t, MockGrantRole("Org9MSP", "admin"))
+	assert.NotNil(t, MockCreateUser(user1.ID, user1.Name, user1.Email))
+	Stub.SetCreator("Org2MSP", "teller")
+	assert.NotNil(t, MockCreateUser(user1.ID, user1.Name, user1.Email))
+	res := Stub.MockInvoke("uuid", [][]byte{[]byte("TrustCertificateRole"), []byte("Org2MSP"), []byte("teller")})
+	assert.NotEqual(t, res.Status, int32(shim.OK))
+	Stub.SetCreator("Org1MSP", "")
+	res = Stub.MockInvoke("uuid", [][]byte{[]byte("TrustCertificateRole"), []byte("Org2MSP"), []byte("teller")})
+	assert.Equal(t, res.Status, int32(shim.OK))
+
+	Stub.SetCreator("Org2MSP", "teller")
+	assert.NotNil(t, MockInitLedger())
+	assert.NotNil(t, MockCreateBank(testbank))
+	assert.Nil(t, MockCreateUser(user1.ID, user1.Name, user1.Email))
+	_, err = MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	assert.Nil(t, err)
+	assert.NotNil(t, MockDeleteUser(user1.ID, "account closed"))
+
+	Stub.SetCreator("Org2MSP", "")
+	assert.NotNil(t, MockCreateUser(user2.ID, user2.Name, user2.Email))
+	assert.NotNil(t, MockGrantRole("Org2MSP", "bank-admin"))
+
+	// Org1MSP was made admin by the first InitLedger
+	Stub.SetCreator("Org1MSP", "")
+	assert.Nil(t, MockGrantRole("Org2MSP", "bank-admin"))
+
+	Stub.SetCreator("Org2MSP", "")
+	assert.Nil(t, MockCreateBank(testbank))
+	assert.Nil(t, MockCreateUser(user2.ID, user2.Name, user2.Email))
+
+	Stub.SetCreator("Org1MSP", "")
+	res = Stub.MockInvoke("uuid", [][]byte{[]byte("SetFunctionRoles"), []byte("CreateBank"), []byte(`["admin"]`)})
+	assert.Equal(t, res.Status, int32(shim.OK))
+	res = Stub.MockInvoke("uuid", [][]byte{[]byte("RevokeRole"), []byte("Org2MSP"), []byte("bank-admin")})
+	assert.Equal(t, res.Status, int32(shim.OK))
+	res = Stub.MockInvoke("uuid", [][]byte{[]byte("TrustCertificateRole"), []byte("Org2MSP"), []byte("bank-admin")})
+	assert.Equal(t, res.Status, int32(shim.OK))
+
+	Stub.SetCreator("Org2MSP", "bank-admin")
+	assert.NotNil(t, MockCreateBank(smartcontract.Bank{ID: "654321", Name: testbank.Name, SwiftCode: testbank.SwiftCode, Country: testbank.Country}))
+	assert.Nil(t, MockCreateUser("3", "Ken Wu", "ken.wu@g.com"))
+
+	Stub.SetCreator("Org1MSP", "")
+	res = Stub.MockInvoke("uuid", [][]byte{[]byte("DistrustCertificateRole"), []byte("Org2MSP"), []byte("bank-admin")})
+	assert.Equal(t, res.Status, int32(shim.OK))
+	res = Stub.MockInvoke("uuid", [][]byte{[]byte("DistrustCertificateRole"), []byte("Org2MSP"), []byte("bank-admin")})
+	assert.NotEqual(t, res.Status, int32(shim.OK))
+
+	Stub.SetCreator("Org2MSP", "bank-admin")
+	assert.NotNil(t, MockCreateUser("4", "Mei Chen", "mei.chen@g.com"))
+}
+
+func Test_Events(t *testing.T) {
+	fmt.Println("Test_Events-----------------")
+	NewStub()
+	MockInitLedger()
+
+	MockCreateUser(user1.ID, user1.Name, user1.Email)
+	envelope := LastEvent(t, "UserCreated")
+	assert.Equal(t, envelope.Version, "1.0")
+	assert.Equal(t, envelope.Function, "CreateUser")
+	assert.Equal(t, envelope.Events[0].EntityID, user1.ID)
+
+	MockUpdateUser(user1.ID, "change name", user1.Email)
+	envelope = LastEvent(t, "UserUpdated")
+	assert.NotContains(t, envelope.Events[0].Data, "name")
+	assert.NotEqual(t, envelope.Events[0].Data.(map[string]interface{})["name_hash"], "")
+
+	MockCreateTransaction(user1.ID, transaction1.Hash, transaction1.Amount, transaction1.Currency, transaction1.Date, bank.ID)
+	envelope = LastEvent(t, "TransactionCreated")
+	assert.Equal(t, len(envelope.Events), 3)
+	assert.Equal(t, envelope.Events[0].EntityID, transaction1.Hash)
+	assert.Equal(t, envelope.Events[1].Type, "BalanceUpdated")
+	assert.Equal(t, envelope.Events[1].Data.(map[string]interface{})["balance"], "200.00")
+	assert.Equal(t, envelope.Events[2].Type, "BankUpdated")
+	assert.Equal(t, envelope.Events[2].Data.(map[string]interface{})["transaction_count"], float64(1))
+
+	MockCreateBank(testbank)
+	envelope = LastEvent(t, "BankCreated")
+	assert.Equal(t, envelope.Events[0].EntityID, testbank.ID)
+
+	MockDeleteUser(user1.ID, "account closed")
+	envelope = LastEvent(t, "UserDeactivated")
+	assert.Equal(t, envelope.Events[0].Data.(map[string]interface{})["deactivation_reason"], "account closed")
+
+	MockGetBankByID(bank.ID)
+	assert.Nil(t, Stub.Event)
+}
+
+// LastEvent decodes the event envelope set by the last invocation
+func LastEvent(t *testing.T, name string) *smartcontract.EventEnvelope {
+	if Stub.Event == nil {
+		t.Fatalf("no %s event was emitted", name)
+	}
+	assert.Equal(t, Stub.Event.EventName, name)
+	var envelope smartcontract.EventEnvelope
+	err := json.Unmarshal(Stub.Event.Payload, &envelope)
+	if err != nil {
+		t.Fatalf("failed to decode the %s event: %v", name, err)
+	}
+	return &envelope
+}
+
+//
+// 
+// Mock function
+
+func MockUserExists(id string) (bool, error) {
+	res := Stub.MockInvoke("uuid", [][]byte{[]byte("UserExists"), []byte(id)})
+	if res.Status != shim.OK {
+		return false, errors.New("UserExists error")
+	}
+	var result bool = false
+	json.Unmarshal(res.Payload, &result)
+	return result, nil
+}
+
+// PIITransient returns the transient map carrying the personal data of a user
+func PIITransient(name string, email string, salt string) map[string][]byte {
+	input, _ := json.Marshal(smartcontract.PIIInput{Name: name, Email: email, Salt: salt})
+	return map[string][]byte{"user": input}
+}
+
+func MockCreateUser(id string, name string, email string) error {
+	res := Stub.MockInvokeWithTransient("uuid",
 		[][]byte{
 			[]byte("CreateUser"),
 			[]byte(id),
-			[]byte(name),
-			[]byte(email),
-		})
+		}, PIITransient(name, email, testSalt))
 
 	if res.Status != shim.OK {
 		fmt.Println("CreateUser failed", string(res.Message))
@@ -301,12 +1765,14 @@ func MockCreateUser(id string, name string, email string) error {
 	return nil
 }
 
-func MockCreateBank(bankId string, name string) error {
+func MockCreateBank(bank smartcontract.Bank) error {
 	res := Stub.MockInvoke("uuid",
 		[][]byte{
 			[]byte("CreateBank"),
-			[]byte(bankId),
-			[]byte(name),
+			[]byte(bank.ID),
+			[]byte(bank.Name),
+			[]byte(bank.SwiftCode),
+			[]byte(bank.Country),
 		})
 	
 		if res.Status != shim.OK {
@@ -316,6 +1782,60 @@ func MockCreateBank(bankId string, name string) error {
 		return nil
 }
 
+func MockUpdateBank(bankId string, name string, swiftCode string, country string) error {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("UpdateBank"),
+			[]byte(bankId),
+			[]byte(name),
+			[]byte(swiftCode),
+			[]byte(country),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("UpdateBank failed", string(res.Message))
+		return errors.New("UpdateBank error")
+	}
+	return nil
+}
+
+func MockDeactivateBank(bankId string) error {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("DeactivateBank"),
+			[]byte(bankId),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("DeactivateBank failed", string(res.Message))
+		return errors.New("DeactivateBank error")
+	}
+	return nil
+}
+
+func MockGetAllBanks() ([]*smartcontract.Bank, error) {
+	res := Stub.MockInvoke("uuid", [][]byte{[]byte("GetAllBanks")})
+	if res.Status != shim.OK {
+		fmt.Println("GetAllBanks failed", string(res.Message))
+		return nil, errors.New("GetAllBanks error")
+	}
+	var banks []*smartcontract.Bank
+	json.Unmarshal(res.Payload, &banks)
+	return banks, nil
+}
+
+func MockGrantRole(mspId string, role string) error {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GrantRole"),
+			[]byte(mspId),
+			[]byte(role),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GrantRole failed", string(res.Message))
+		return errors.New("GrantRole error")
+	}
+	return nil
+}
+
 func MockGetUser(id string) (*smartcontract.User, error) {
 	var result smartcontract.User
 	res := Stub.MockInvoke("uuid",
@@ -347,13 +1867,11 @@ func MockGetUserByTransactionHash(hash string) (*smartcontract.User, error) {
 }
 
 func MockUpdateUser(id string, name string, email string) error {
-	res := Stub.MockInvoke("uuid",
+	res := Stub.MockInvokeWithTransient("uuid",
 		[][]byte{
 			[]byte("UpdateUser"),
 			[]byte(id),
-			[]byte(name),
-			[]byte(email),
-		})
+		}, PIITransient(name, email, testSalt+"-"+name))
 	if res.Status != shim.OK {
 		fmt.Println("UpdateUser failed", string(res.Message))
 		return errors.New("UpdateUser error")
@@ -361,11 +1879,12 @@ func MockUpdateUser(id string, name string, email string) error {
 	return nil
 }
 
-func MockDeleteUser(id string) error {
+func MockDeleteUser(id string, reason string) error {
 	res := Stub.MockInvoke("uuid",
 		[][]byte{
 			[]byte("DeleteUser"),
 			[]byte(id),
+			[]byte(reason),
 		})
 	if res.Status != shim.OK {
 		fmt.Println("DeleteUser failed", string(res.Message))
@@ -374,8 +1893,49 @@ func MockDeleteUser(id string) error {
 	return nil
 }
 
-func MockGetAllUsers() ([]*smartcontract.User, error) {
-	res := Stub.MockInvoke("uuid", [][]byte{[]byte("GetAllUsers")})
+func MockRestoreUser(id string) error {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("RestoreUser"),
+			[]byte(id),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("RestoreUser failed", string(res.Message))
+		return errors.New("RestoreUser error")
+	}
+	return nil
+}
+
+func MockPurgeUser(id string) error {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("PurgeUser"),
+			[]byte(id),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("PurgeUser failed", string(res.Message))
+		return errors.New("PurgeUser error")
+	}
+	return nil
+}
+
+func MockGetUserHistory(id string) ([]*smartcontract.UserHistoryEntry, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GetUserHistory"),
+			[]byte(id),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GetUserHistory failed", string(res.Message))
+		return nil, errors.New("GetUserHistory error")
+	}
+	var result []*smartcontract.UserHistoryEntry
+	json.Unmarshal(res.Payload, &result)
+	return result, nil
+}
+
+func MockGetAllUsers(includeDeactivated bool) ([]*smartcontract.User, error) {
+	res := Stub.MockInvoke("uuid", [][]byte{[]byte("GetAllUsers"), []byte(fmt.Sprint(includeDeactivated))})
 	if res.Status != shim.OK {
 		fmt.Println("GetAllUsers failed", string(res.Message))
 		return nil, errors.New("GetAllUsers error")
@@ -385,8 +1945,25 @@ func MockGetAllUsers() ([]*smartcontract.User, error) {
 	return users, nil
 }
 
+func MockGetUsersPage(pageSize int32, bookmark string, includeDeactivated bool) (*smartcontract.UserPage, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GetUsersPage"),
+			[]byte(fmt.Sprint(pageSize)),
+			[]byte(bookmark),
+			[]byte(fmt.Sprint(includeDeactivated)),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GetUsersPage failed", string(res.Message))
+		return nil, errors.New("GetUsersPage error")
+	}
+	var result smartcontract.UserPage
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
 // 新增 MockCreateTransaction
-func MockCreateTransaction(userId string, hash string, amount string, currency string, date string, bankId string) (bool, error) {
+func MockCreateTransaction(userId string, hash string, amount string, currency string, date string, bankId string) (*smartcontract.TransactionResult, error) {
 	res := Stub.MockInvoke("uuid",
 		[][]byte{
 			[]byte("CreateTransaction"),
@@ -399,9 +1976,320 @@ func MockCreateTransaction(userId string, hash string, amount string, currency s
 		})
 	if res.Status != shim.OK {
 		fmt.Println("CreateTransaction failed", string(res.Message))
-		return false, errors.New("CreateTransaction error")
+		return nil, errors.New("CreateTransaction error")
 	}
-	var result bool = false
+	var result smartcontract.TransactionResult
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+// batchError decodes the BatchError of a rejected batch
+func batchError(function string, message string) error {
+	fmt.Println(function, "failed", message)
+	var batchErr smartcontract.BatchError
+	if json.Unmarshal([]byte(message), &batchErr) != nil || batchErr.Items == nil {
+		return errors.New(function + " error")
+	}
+	return &batchErr
+}
+
+func MockBatchCreateUsers(ids []string, users map[string]smartcontract.PIIInput) (*smartcontract.BatchResult, error) {
+	idsJson, _ := json.Marshal(ids)
+	usersJson, _ := json.Marshal(users)
+	res := Stub.MockInvokeWithTransient("uuid",
+		[][]byte{
+			[]byte("BatchCreateUsers"),
+			idsJson,
+		}, map[string][]byte{"users": usersJson})
+	if res.Status != shim.OK {
+		return nil, batchError("BatchCreateUsers", res.Message)
+	}
+	var result smartcontract.BatchResult
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+func MockBatchCreateTransactions(transactions []smartcontract.Transaction) (*smartcontract.BatchResult, error) {
+	transactionsJson, _ := json.Marshal(transactions)
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("BatchCreateTransactions"),
+			transactionsJson,
+		})
+	if res.Status != shim.OK {
+		return nil, batchError("BatchCreateTransactions", res.Message)
+	}
+	var result smartcontract.BatchResult
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+func MockTransferBetweenUsers(txId string, fromId string, toId string, amount string, currency string, reference string) (*smartcontract.Transfer, error) {
+	res := Stub.MockInvoke(txId,
+		[][]byte{
+			[]byte("TransferBetweenUsers"),
+			[]byte(fromId),
+			[]byte(toId),
+			[]byte(amount),
+			[]byte(currency),
+			[]byte(reference),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("TransferBetweenUsers failed", string(res.Message))
+		return nil, errors.New("TransferBetweenUsers error")
+	}
+	var result smartcontract.Transfer
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+func MockReverseTransaction(hash string, reason string) (*smartcontract.Transaction, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("ReverseTransaction"),
+			[]byte(hash),
+			[]byte(reason),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("ReverseTransaction failed", string(res.Message))
+		return nil, errors.New("ReverseTransaction error")
+	}
+	var result smartcontract.Transaction
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+func MockGetUserTransactions(userId string, pageSize int32, bookmark string) (*smartcontract.TransactionPage, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GetUserTransactions"),
+			[]byte(userId),
+			[]byte(fmt.Sprint(pageSize)),
+			[]byte(bookmark),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GetUserTransactions failed", string(res.Message))
+		return nil, errors.New("GetUserTransactions error")
+	}
+	var result smartcontract.TransactionPage
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+func MockQueryUsers(selector string, pageSize int32, bookmark string) (*smartcontract.UserPage, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("QueryUsers"),
+			[]byte(selector),
+			[]byte(fmt.Sprint(pageSize)),
+			[]byte(bookmark),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("QueryUsers failed", string(res.Message))
+		return nil, errors.New("QueryUsers error")
+	}
+	var result smartcontract.UserPage
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+func MockGetUserPII(id string) (*smartcontract.UserPII, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GetUserPII"),
+			[]byte(id),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GetUserPII failed", string(res.Message))
+		return nil, errors.New("GetUserPII error")
+	}
+	var pii smartcontract.UserPII
+	json.Unmarshal(res.Payload, &pii)
+	return &pii, nil
+}
+
+func MockVerifyUserPII(id string, name string, email string, salt string) (bool, error) {
+	res := Stub.MockInvokeWithTransient("uuid",
+		[][]byte{
+			[]byte("VerifyUserPII"),
+			[]byte(id),
+		}, PIITransient(name, email, salt))
+	if res.Status != shim.OK {
+		fmt.Println("VerifyUserPII failed", string(res.Message))
+		return false, errors.New("VerifyUserPII error")
+	}
+	return string(res.Payload) == "true", nil
+}
+
+func MockGetUserByEmail(email string) (*smartcontract.UserPII, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GetUserByEmail"),
+			[]byte(email),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GetUserByEmail failed", string(res.Message))
+		return nil, errors.New("GetUserByEmail error")
+	}
+	var user smartcontract.UserPII
+	json.Unmarshal(res.Payload, &user)
+	return &user, nil
+}
+
+func MockGetUsersByName(name string) ([]*smartcontract.UserPII, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GetUsersByName"),
+			[]byte(name),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GetUsersByName failed", string(res.Message))
+		return nil, errors.New("GetUsersByName error")
+	}
+	var users []*smartcontract.UserPII
+	json.Unmarshal(res.Payload, &users)
+	return users, nil
+}
+
+func MockQueryTransactions(selector string, pageSize int32, bookmark string) (*smartcontract.TransactionPage, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("QueryTransactions"),
+			[]byte(selector),
+			[]byte(fmt.Sprint(pageSize)),
+			[]byte(bookmark),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("QueryTransactions failed", string(res.Message))
+		return nil, errors.New("QueryTransactions error")
+	}
+	var result smartcontract.TransactionPage
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+func MockGetTransactionsByBank(bankId string, pageSize int32, bookmark string) (*smartcontract.TransactionPage, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GetTransactionsByBank"),
+			[]byte(bankId),
+			[]byte(fmt.Sprint(pageSize)),
+			[]byte(bookmark),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GetTransactionsByBank failed", string(res.Message))
+		return nil, errors.New("GetTransactionsByBank error")
+	}
+	var result smartcontract.TransactionPage
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+func MockMigrateKeys() (*smartcontract.MigrationResult, error) {
+	res := Stub.MockInvokeWithTransient("uuid", [][]byte{[]byte("MigrateKeys")},
+		map[string][]byte{"salt": []byte(testSalt)})
+	if res.Status != shim.OK {
+		fmt.Println("MigrateKeys failed", string(res.Message))
+		return nil, errors.New("MigrateKeys error")
+	}
+	var result smartcontract.MigrationResult
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+func MockGetUserBalance(userId string, currency string) (*smartcontract.UserBalance, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GetUserBalance"),
+			[]byte(userId),
+			[]byte(currency),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GetUserBalance failed", string(res.Message))
+		return nil, errors.New("GetUserBalance error")
+	}
+	var result smartcontract.UserBalance
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+func MockSetFxRate(from string, to string, rate string, effectiveDate string) error {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("SetFxRate"),
+			[]byte(from),
+			[]byte(to),
+			[]byte(rate),
+			[]byte(effectiveDate),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("SetFxRate failed", string(res.Message))
+		return errors.New("SetFxRate error")
+	}
+	return nil
+}
+
+func MockGetFxRate(from string, to string, date string) (*smartcontract.FxRate, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GetFxRate"),
+			[]byte(from),
+			[]byte(to),
+			[]byte(date),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GetFxRate failed", string(res.Message))
+		return nil, errors.New("GetFxRate error")
+	}
+	var result smartcontract.FxRate
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+func MockGetUserBalanceIn(userId string, currency string) (*smartcontract.UserBalance, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GetUserBalanceIn"),
+			[]byte(userId),
+			[]byte(currency),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GetUserBalanceIn failed", string(res.Message))
+		return nil, errors.New("GetUserBalanceIn error")
+	}
+	var result smartcontract.UserBalance
+	json.Unmarshal(res.Payload, &result)
+	return &result, nil
+}
+
+func MockGetUserBalances(userId string) ([]*smartcontract.UserBalance, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GetUserBalances"),
+			[]byte(userId),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GetUserBalances failed", string(res.Message))
+		return nil, errors.New("GetUserBalances error")
+	}
+	var result []*smartcontract.UserBalance
+	json.Unmarshal(res.Payload, &result)
+	return result, nil
+}
+
+func MockGetBankStats(bankId string, fromDate string, toDate string) ([]*smartcontract.BankDailyStats, error) {
+	res := Stub.MockInvoke("uuid",
+		[][]byte{
+			[]byte("GetBankStats"),
+			[]byte(bankId),
+			[]byte(fromDate),
+			[]byte(toDate),
+		})
+	if res.Status != shim.OK {
+		fmt.Println("GetBankStats failed", string(res.Message))
+		return nil, errors.New("GetBankStats error")
+	}
+	var result []*smartcontract.BankDailyStats
 	json.Unmarshal(res.Payload, &result)
 	return result, nil
 }
@@ -422,9 +2310,11 @@ func MockGetBankByID(id string) (*smartcontract.Bank, error) {
 }
 
 func MockInitLedger() (error) {
+	banksJson, _ := json.Marshal([]smartcontract.Bank{bank, fubonBank})
 	res := Stub.MockInvoke("uuid",
 		[][]byte{
 			[]byte("InitLedger"),
+			banksJson,
 		})
 		if res.Status != shim.OK {
 			fmt.Println("MockInitLedger failed", string(res.Message))
@@ -18,18 +18,24 @@ while [[ $# -ge 1 ]] ; do
   key="$1"
   case $key in
   init ) # init
-    peer chaincode invoke -o localhost:7050 -C mychannel -n $CHAINCODE_NAME --peerAddresses localhost:7051 --isInit -c '{"function":"InitLedger","Args":[]}'
+    peer chaincode invoke -o localhost:7050 -C mychannel -n $CHAINCODE_NAME --peerAddresses localhost:7051 --isInit -c '{"function":"InitLedger","Args":["[{\"id\":\"04231910\",\"name\":\"國泰世華商業銀行\",\"swift_code\":\"UWCBTWTP\",\"country\":\"TW\"},{\"id\":\"03750168\",\"name\":\"台北富邦商業銀行\",\"swift_code\":\"TPBKTWTP\",\"country\":\"TW\"}]"]}'
     shift
     ;;
   1 ) # Query
     peer chaincode query -C mychannel -n $CHAINCODE_NAME -c '{"function":"GetUser","Args":["1"]}'
-    peer chaincode query -C mychannel -n $CHAINCODE_NAME -c '{"function":"GetAllUsers","Args":[]}'
+    peer chaincode query -C mychannel -n $CHAINCODE_NAME -c '{"function":"GetAllUsers","Args":["false"]}'
+    peer chaincode query -C mychannel -n $CHAINCODE_NAME -c '{"function":"GetAllBanks","Args":[]}'
+    peer chaincode query -C mychannel -n $CHAINCODE_NAME -c '{"function":"GetUserPII","Args":["1"]}'
+    peer chaincode query -C mychannel -n $CHAINCODE_NAME -c '{"function":"GetUserByEmail","Args":["evan@gmail.com"]}'
     shift
     ;;
   2 ) # Invoke
-    peer chaincode invoke -o localhost:7050 -C mychannel -n $CHAINCODE_NAME --peerAddresses localhost:7051 -c '{"function":"CreateUser","Args":["1","Evan","evan@gmail.com"]}'
-    peer chaincode invoke -o localhost:7050 -C mychannel -n $CHAINCODE_NAME --peerAddresses localhost:7051 -c '{"function":"CreateUser","Args":["2","Amy","amy@gmail.com"]}'
-    peer chaincode invoke -o localhost:7050 -C mychannel -n $CHAINCODE_NAME --peerAddresses localhost:7051 -c '{"function":"DeleteUser","Args":["2"]}'
+    # Personal data goes through the transient map, base64 encoded, with a random salt
+    USER1=$(echo -n "{\"name\":\"Evan\",\"email\":\"evan@gmail.com\",\"salt\":\"$(openssl rand -hex 16)\"}" | base64 | tr -d '\n')
+    USER2=$(echo -n "{\"name\":\"Amy\",\"email\":\"amy@gmail.com\",\"salt\":\"$(openssl rand -hex 16)\"}" | base64 | tr -d '\n')
+    peer chaincode invoke -o localhost:7050 -C mychannel -n $CHAINCODE_NAME --peerAddresses localhost:7051 -c '{"function":"CreateUser","Args":["1"]}' --transient "{\"user\":\"$USER1\"}"
+    peer chaincode invoke -o localhost:7050 -C mychannel -n $CHAINCODE_NAME --peerAddresses localhost:7051 -c '{"function":"CreateUser","Args":["2"]}' --transient "{\"user\":\"$USER2\"}"
+    peer chaincode invoke -o localhost:7050 -C mychannel -n $CHAINCODE_NAME --peerAddresses localhost:7051 -c '{"function":"DeleteUser","Args":["2","account closed"]}'
     shift
     ;;
   * )